@@ -1,10 +1,16 @@
 package main
 
 import (
+	"flag"
 	"fmt"
-	"math/rand"
+	"os"
+	"slices"
 	"sync"
 	"time"
+
+	"github.com/Jashveragiwala/Lamport-and-Vector-Clocks/chance"
+	"github.com/Jashveragiwala/Lamport-and-Vector-Clocks/config"
+	"github.com/Jashveragiwala/Lamport-and-Vector-Clocks/loss"
 )
 
 type Message struct {
@@ -22,12 +28,14 @@ type Client struct {
 type Server struct {
 	serverChannel chan Message
 	clientsArray  []*Client
+	dropPolicy    loss.DropPolicy
 }
 
-const MESSAGE_DELAY = 500
+var MESSAGE_DELAY = 500 * time.Millisecond
 
 var NUM_CLIENTS int
 var NUM_MESSAGES int
+var SEED int64
 
 // Periodically each client sends a message to the server
 func (c *Client) clientSender(wg *sync.WaitGroup) {
@@ -35,7 +43,7 @@ func (c *Client) clientSender(wg *sync.WaitGroup) {
 
 	msgCount := 1
 	for {
-		time.Sleep(MESSAGE_DELAY * time.Millisecond)
+		time.Sleep(MESSAGE_DELAY)
 		fmt.Printf("\033[34mClient %d is sending Message %d\033[0m\n", c.clientID, msgCount)
 		c.server.serverChannel <- Message{c.clientID, msgCount}
 		// Stop when the client has sent the number of messages
@@ -47,7 +55,7 @@ func (c *Client) clientSender(wg *sync.WaitGroup) {
 	c.closeChannel <- true
 }
 
-// Server listens for messages from clients and broadcasts them to rest of the clients based on a coin toss
+// Server listens for messages from clients and broadcasts them to rest of the clients unless the drop policy drops them
 func (s *Server) serverListener(wg *sync.WaitGroup) {
 	defer wg.Done()
 	fmt.Println("Server is listening for Messages...")
@@ -60,17 +68,27 @@ func (s *Server) serverListener(wg *sync.WaitGroup) {
 				return
 			}
 			fmt.Printf("\033[32mServer received Message %d from Client %d\033[0m\n", clientMessage.messageID, clientMessage.senderID)
-			// Server flips a coin to decide whether to broadcast the message or drop it
-			coinToss := rand.Intn(2)
-			if coinToss == 0 {
-				for _, client := range s.clientsArray {
-					if client.clientID != clientMessage.senderID {
-						go s.serverSender(client, clientMessage)
-					}
+			// Server asks the drop policy which of the other clients the message is dropped for
+			receivers := []int{}
+			for _, client := range s.clientsArray {
+				if client.clientID != clientMessage.senderID {
+					receivers = append(receivers, client.clientID)
 				}
-			} else {
+			}
+			dropped := s.dropPolicy.Drop(clientMessage.senderID, clientMessage.messageID, receivers)
+			if len(dropped) == len(receivers) {
 				fmt.Printf("\033[31mServer has dropped Message %d from Client %d\033[0m\n", clientMessage.messageID, clientMessage.senderID)
 			}
+			for _, client := range s.clientsArray {
+				if client.clientID == clientMessage.senderID {
+					continue
+				}
+				if !slices.Contains(dropped, client.clientID) {
+					go s.serverSender(client, clientMessage)
+				} else if len(dropped) != len(receivers) {
+					fmt.Printf("\033[31mServer has dropped Message %d from Client %d for Client %d\033[0m\n", clientMessage.messageID, clientMessage.senderID, client.clientID)
+				}
+			}
 			if clientMessage.messageID == NUM_MESSAGES {
 				doneClients++
 				if doneClients == NUM_CLIENTS {
@@ -102,30 +120,67 @@ func (c *Client) clientListener(wg *sync.WaitGroup) {
 }
 
 func main() {
-	rand.Seed(time.Now().UnixNano())
-	// Get user input for number of clients
-	for {
-		fmt.Print("Enter the number of clients (at least 2): ")
-		fmt.Scan(&NUM_CLIENTS)
-		if NUM_CLIENTS < 2 {
-			fmt.Println("You need at least 2 clients to communicate.")
-		} else {
-			break
+	drop := flag.String("drop", "fixed:0.5", loss.Usage)
+	flag.IntVar(&NUM_CLIENTS, "clients", 0, "number of clients, asked for when not given")
+	flag.IntVar(&NUM_MESSAGES, "messages", 0, "number of messages each client sends, -1 for infinite messages, asked for when not given")
+	flag.DurationVar(&MESSAGE_DELAY, "delay", MESSAGE_DELAY, "time between two messages of a client")
+	flag.Int64Var(&SEED, "seed", 0, "seed of every random decision, taken from the clock when 0")
+	record := flag.String("record", "", "record every random decision to this JSON Lines file")
+	replay := flag.String("replay", "", "repeat the random decisions recorded in this file, as long as the run asks for the same ones")
+	configFile := flag.String("config", "", config.Usage)
+	flag.Parse()
+	if err := config.Load(flag.CommandLine, *configFile); err != nil {
+		fmt.Println(err)
+		os.Exit(2)
+	}
+	// Every random decision comes from one source, so that a run can be repeated from its seed or its recording
+	random, err := chance.Open(SEED, *record, *replay)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(2)
+	}
+	defer random.Close()
+	dropPolicy, err := loss.Parse(*drop, random.For("drop"))
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(2)
+	}
+
+	// Get user input for number of clients unless it was given
+	if NUM_CLIENTS == 0 {
+		for {
+			fmt.Print("Enter the number of clients (at least 2): ")
+			fmt.Scan(&NUM_CLIENTS)
+			if NUM_CLIENTS < 2 {
+				fmt.Println("You need at least 2 clients to communicate.")
+			} else {
+				break
+			}
 		}
+	} else if NUM_CLIENTS < 2 {
+		fmt.Println("You need at least 2 clients to communicate.")
+		os.Exit(2)
 	}
-	// Get user input for number of messages per client
-	for {
-		fmt.Print("Enter the number of messages each client will send (enter -1 for infinite messages): ")
-		fmt.Scan(&NUM_MESSAGES)
-		if NUM_MESSAGES == 0 {
-			fmt.Println("There is nothing to communicate. Please enter a valid number of messages.")
-		} else {
-			break
+	// Get user input for number of messages per client unless it was given
+	if NUM_MESSAGES == 0 {
+		for {
+			fmt.Print("Enter the number of messages each client will send (enter -1 for infinite messages): ")
+			fmt.Scan(&NUM_MESSAGES)
+			if NUM_MESSAGES == 0 {
+				fmt.Println("There is nothing to communicate. Please enter a valid number of messages.")
+			} else if NUM_MESSAGES < -1 {
+				fmt.Println("Invalid input. Please enter at least 1 or -1 for infinite messages.")
+			} else {
+				break
+			}
 		}
+	} else if NUM_MESSAGES < -1 {
+		fmt.Println("Invalid number of messages. Please give at least 1 or -1 for infinite messages.")
+		os.Exit(2)
 	}
 
 	// Initialize server
-	server := Server{serverChannel: make(chan Message, 10), clientsArray: make([]*Client, NUM_CLIENTS)}
+	server := Server{serverChannel: make(chan Message, 10), clientsArray: make([]*Client, NUM_CLIENTS), dropPolicy: dropPolicy}
 	var wg sync.WaitGroup
 
 	// Initialize clients and add them to the server's clients array
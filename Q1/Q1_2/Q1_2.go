@@ -1,48 +1,135 @@
 package main
 
 import (
+	"flag"
 	"fmt"
-	"math/rand"
+	"hash/fnv"
+	"os"
 	"sync"
 	"time"
+
+	"github.com/Jashveragiwala/Lamport-and-Vector-Clocks/chance"
+	"github.com/Jashveragiwala/Lamport-and-Vector-Clocks/clock"
+	"github.com/Jashveragiwala/Lamport-and-Vector-Clocks/config"
+	"github.com/Jashveragiwala/Lamport-and-Vector-Clocks/delivery"
+	"github.com/Jashveragiwala/Lamport-and-Vector-Clocks/loss"
+	"github.com/Jashveragiwala/Lamport-and-Vector-Clocks/netsim"
+	"github.com/Jashveragiwala/Lamport-and-Vector-Clocks/shiviz"
+	"github.com/Jashveragiwala/Lamport-and-Vector-Clocks/sim"
+	"github.com/Jashveragiwala/Lamport-and-Vector-Clocks/transport"
 )
 
 type Message struct {
 	senderID  int
 	messageID int
 	clock     int
+	kind      int
+	timestamp int // Lamport timestamp given by the original sender, used to order deliveries
+	ackerID   int // Client acknowledging the message, for ACK_MESSAGE
+	seq       int // Number of the message on the link it was sent over, so the receiver can restore their order
+
+	vectorTimeStamp []int // Only used for the ShiViz log
 }
 
 type Client struct {
-	clientID      int
-	clientChannel chan Message
-	server        *Server
-	closeChannel  chan bool
-	lamportClock  int
+	clientID     int
+	transport    transport.Transport
+	lamportClock *clock.LamportClock
+	vectorClock  *clock.VectorClock
+	sendLock     sync.Mutex // Keeps messages to the server in timestamp order
+	holdBack     *delivery.TotalOrderQueue[Message]
+	deliveryLog  []string
+	expected     int // Number of messages to deliver, known once the server flushes
+	doneSent     bool
+	sent         int                          // Messages sent to the server so far
+	inbox        *delivery.FIFOQueue[Message] // Restores the order of the messages from the server
 }
 
 type Server struct {
-	serverChannel chan Message
-	clientsArray  []*Client
-	lamportClock  int
+	transport     transport.Transport
+	lamportClock  *clock.LamportClock
+	vectorClock   *clock.VectorClock
+	dropPolicy    loss.DropPolicy
+	inbox         *delivery.FIFOQueue[Message] // Restores the order of the messages from every client
+	sent          []int                        // Messages sent to each client so far
+	doneClients   int                          // Clients whose every message has arrived
+	forwarded     int
+	doneListening map[int]bool // Clients that have delivered every forwarded message
 }
 
-const MESSAGE_DELAY = 1000
+var MESSAGE_DELAY = 1000 * time.Millisecond
+
+const (
+	DATA_MESSAGE  = 1
+	ACK_MESSAGE   = 2
+	FLUSH_MESSAGE = 3 // Server has forwarded every message, messageID holds how many
+	DONE_MESSAGE  = 4 // Client has delivered every forwarded message
+	CLOSE_MESSAGE = 5 // Server has nothing more to send to the client
+)
 
 var NUM_CLIENTS int
 var NUM_MESSAGES int
+var SHIVIZ_FILE string
+var ADDRESS = "localhost:9000"
+var CLIENT_ID = 1
+var SEED int64
 
-// Update and return Lamport clock
-func updateLamportClock(localClock, remoteClock int) int {
-	return max(localClock, remoteClock) + 1
+var shivizLog *shiviz.Logger // nil unless a ShiViz log was requested
+
+// Converts a message to its wire form. An acknowledgement goes out as coming from the acking client, so the
+// server can tell who acknowledged it from the connection it arrives on
+func (msg Message) wire() transport.Message {
+	wireMessage := transport.Message{Kind: msg.kind, Sender: msg.senderID, MessageID: msg.messageID, Clock: msg.clock, Timestamp: msg.timestamp, Seq: msg.seq, Vector: msg.vectorTimeStamp}
+	if msg.kind == ACK_MESSAGE {
+		wireMessage.Sender, wireMessage.Origin = msg.ackerID, msg.senderID
+	}
+	return wireMessage
 }
 
-// Helper to get max of two integers
-func max(a, b int) int {
-	if a > b {
-		return a
+// Converts a message from its wire form
+func fromWire(wireMessage transport.Message) Message {
+	msg := Message{senderID: wireMessage.Sender, messageID: wireMessage.MessageID, clock: wireMessage.Clock, kind: wireMessage.Kind, timestamp: wireMessage.Timestamp, seq: wireMessage.Seq, vectorTimeStamp: wireMessage.Vector}
+	if msg.kind == ACK_MESSAGE {
+		msg.senderID, msg.ackerID = wireMessage.Origin, wireMessage.Sender
 	}
-	return b
+	return msg
+}
+
+// Describes a message for the ShiViz log
+func (msg Message) describe() string {
+	switch msg.kind {
+	case ACK_MESSAGE:
+		return fmt.Sprintf("acknowledgement of Message %d from Client %d by Client %d", msg.messageID, msg.senderID, msg.ackerID)
+	case FLUSH_MESSAGE:
+		return fmt.Sprintf("flush after %d forwarded messages", msg.messageID)
+	case DONE_MESSAGE:
+		return fmt.Sprintf("done from Client %d", msg.senderID)
+	}
+	return fmt.Sprintf("Message %d from Client %d", msg.messageID, msg.senderID)
+}
+
+// Client stamps a message with its Lamport clock and sends it to the server
+func (c *Client) sendToServer(msg Message) int {
+	c.sendLock.Lock()
+	defer c.sendLock.Unlock()
+	msg.clock = c.lamportClock.Send()
+	if msg.kind == DATA_MESSAGE {
+		msg.timestamp = msg.clock
+	}
+	msg.vectorTimeStamp = c.vectorClock.Send()
+	shivizLog.Log(c.clientID, msg.vectorTimeStamp, fmt.Sprintf("Client %d sends %s to Server", c.clientID, msg.describe()))
+	c.sent++
+	msg.seq = c.sent
+	if err := c.transport.Send(0, msg.wire()); err != nil {
+		fmt.Fprintf(os.Stderr, "Client %d could not send %s: %v\n", c.clientID, msg.describe(), err)
+	}
+	return msg.clock
+}
+
+// Client sends the data message with the given ID to the server
+func (c *Client) sendData(messageID int) {
+	timestamp := c.sendToServer(Message{senderID: c.clientID, messageID: messageID, kind: DATA_MESSAGE})
+	fmt.Printf("\033[34m(Lamport Clock of Client %d: %d) Client %d  is sending Message %d to Server\033[0m\n", c.clientID, timestamp, c.clientID, messageID)
 }
 
 // Periodically each client sends a message to the server
@@ -51,126 +138,432 @@ func (c *Client) clientSender(wg *sync.WaitGroup) {
 
 	msgCount := 1
 	for {
-		time.Sleep(MESSAGE_DELAY * time.Millisecond)
-		c.lamportClock++
-		fmt.Printf("\033[34m(Lamport Clock of Client %d: %d) Client %d  is sending Message %d to Server\033[0m\n", c.clientID, c.lamportClock, c.clientID, msgCount)
-		c.server.serverChannel <- Message{c.clientID, msgCount, c.lamportClock}
+		time.Sleep(MESSAGE_DELAY)
+		c.sendData(msgCount)
 		// Stop when the client has sent the number of messages
 		if NUM_MESSAGES != -1 && msgCount >= NUM_MESSAGES {
 			break
 		}
 		msgCount++
 	}
-	c.closeChannel <- true
 }
 
-// Server broadcasts messages to clients concurrently
-func (s *Server) serverSender(client *Client, msg Message) {
-	// Clients update their Lamport clock correctly using the server Lamport clock
-	client.lamportClock = updateLamportClock(client.lamportClock, msg.clock)
-	// Send the message with the server updated clock to the client
-	client.clientChannel <- Message{msg.senderID, msg.messageID, client.lamportClock}
+// Server sends a message to every client except the one given
+func (s *Server) forward(msg Message, exceptID int) {
+	for clientID := 1; clientID <= NUM_CLIENTS; clientID++ {
+		if clientID != exceptID {
+			s.send(clientID, msg)
+		}
+	}
+}
+
+// Server sends a message to a client. Total order delivery relies on the links being FIFO, so every
+// message is numbered for the client to restore the order
+func (s *Server) send(clientID int, msg Message) {
+	s.sent[clientID]++
+	msg.seq = s.sent[clientID]
+	if err := s.transport.Send(clientID, msg.wire()); err != nil {
+		fmt.Fprintf(os.Stderr, "Server could not send %s to Client %d: %v\n", msg.describe(), clientID, err)
+	}
+}
+
+// Server tells every client to stop once everything sent to it before has arrived
+func (s *Server) closeClients() {
+	for clientID := 1; clientID <= NUM_CLIENTS; clientID++ {
+		s.transport.Flush(clientID)
+		s.send(clientID, Message{kind: CLOSE_MESSAGE})
+	}
+	// The server may close its transport next, so the network must have handed over every message
+	for clientID := 1; clientID <= NUM_CLIENTS; clientID++ {
+		s.transport.Flush(clientID)
+	}
 }
 
-// Server listens for messages from clients and broadcasts them concurrently
+// Server listens for messages from clients and broadcasts them
 func (s *Server) serverListener(wg *sync.WaitGroup) {
 	defer wg.Done()
 	fmt.Println("Server is listening for Messages...")
 
-	doneClients := 0
-	for {
-		select {
-		case clientMessage, ok := <-s.serverChannel:
-			if !ok {
-				return
-			}
-			// Update server Lamport clock when receiving a message
-			s.lamportClock = updateLamportClock(s.lamportClock, clientMessage.clock)
-			fmt.Printf("\033[32m(Lamport Clock of Server: %d) Server received Message %d from Client %d\033[0m\n", s.lamportClock, clientMessage.messageID, clientMessage.senderID)
-			// Server flips a coin to decide whether to broadcast the message or drop it
-			coinToss := rand.Intn(2)
-			s.lamportClock++
-			if coinToss == 0 {
-				fmt.Printf("\033[38;5;214m(Lamport Clock of Server: %d) Server is forwarding message %d from Client %d\033[0m\n", s.lamportClock, clientMessage.messageID, clientMessage.senderID)
-				for _, client := range s.clientsArray {
-					if client.clientID != clientMessage.senderID {
-						go s.serverSender(client, Message{clientMessage.senderID, clientMessage.messageID, s.lamportClock})
-					}
-				}
-			} else {
-				fmt.Printf("\033[31m(Lamport Clock of Server: %d) Server has dropped Message %d from Client %d\033[0m\n", s.lamportClock, clientMessage.messageID, clientMessage.senderID)
-			}
-			if clientMessage.messageID == NUM_MESSAGES {
-				doneClients++
-				if doneClients == NUM_CLIENTS {
-					close(s.serverChannel) // Close server channel when all clients are done
-				}
-			}
+	for wireMessage := range s.transport.Receive() {
+		if s.receive(wireMessage) {
+			s.closeClients()
+			return
+		}
+	}
+	fmt.Println("Server lost the connection to the clients.")
+}
+
+// Server puts a message from a client back in the order it was sent and handles every message that is now
+// in order, returning true once every client has delivered every message
+func (s *Server) receive(wireMessage transport.Message) bool {
+	ready, duplicate := s.inbox.Add(wireMessage.Sender, wireMessage.Seq, fromWire(wireMessage))
+	if duplicate {
+		fmt.Printf("\033[33m[Duplicate] Server received %s again and ignores it\033[0m\n", fromWire(wireMessage).describe())
+	}
+	for _, clientMessage := range ready {
+		if s.handle(clientMessage) {
+			return true
 		}
 	}
+	return false
+}
+
+// Server handles a message from a client, returning true once every client has delivered every message
+func (s *Server) handle(clientMessage Message) bool {
+	vectorReceiveTime := s.vectorClock.Merge(clientMessage.vectorTimeStamp)
+	shivizLog.Log(0, vectorReceiveTime, fmt.Sprintf("Server receives %s", clientMessage.describe()))
+	switch clientMessage.kind {
+	case ACK_MESSAGE:
+		// Acknowledgements are never dropped. The acking client gets its own back too, after every message it sent
+		// before it, so that it cannot deliver a message ahead of its own with a lower timestamp
+		s.lamportClock.Receive(clientMessage.clock)
+		clientMessage.clock = s.lamportClock.Send()
+		clientMessage.vectorTimeStamp = s.vectorClock.Send()
+		shivizLog.Log(0, clientMessage.vectorTimeStamp, fmt.Sprintf("Server relays %s", clientMessage.describe()))
+		s.forward(clientMessage, 0)
+		return false
+	case DONE_MESSAGE:
+		s.doneListening[clientMessage.senderID] = true
+		return len(s.doneListening) == NUM_CLIENTS
+	}
+
+	// Update server Lamport clock when receiving a message
+	receiveTime := s.lamportClock.Receive(clientMessage.clock)
+	fmt.Printf("\033[32m(Lamport Clock of Server: %d) Server received Message %d from Client %d\033[0m\n", receiveTime, clientMessage.messageID, clientMessage.senderID)
+	// Server asks the drop policy whether to broadcast the message or drop it. A message dropped for
+	// any client is dropped for all of them, since every client has to acknowledge it before delivery
+	receivers := make([]int, NUM_CLIENTS)
+	for i := range receivers {
+		receivers[i] = i + 1
+	}
+	dropped := s.dropPolicy.Drop(clientMessage.senderID, clientMessage.messageID, receivers)
+	sendTime := s.lamportClock.Send()
+	vectorSendTime := s.vectorClock.Send()
+	if len(dropped) == 0 {
+		fmt.Printf("\033[38;5;214m(Lamport Clock of Server: %d) Server is forwarding message %d from Client %d\033[0m\n", sendTime, clientMessage.messageID, clientMessage.senderID)
+		// The sender gets its own message back so it can deliver it in the total order too
+		clientMessage.clock = sendTime
+		clientMessage.vectorTimeStamp = vectorSendTime
+		shivizLog.Log(0, vectorSendTime, fmt.Sprintf("Server forwards %s", clientMessage.describe()))
+		s.forward(clientMessage, 0)
+		s.forwarded++
+	} else {
+		fmt.Printf("\033[31m(Lamport Clock of Server: %d) Server has dropped Message %d from Client %d\033[0m\n", sendTime, clientMessage.messageID, clientMessage.senderID)
+		shivizLog.Log(0, vectorSendTime, fmt.Sprintf("Server drops %s", clientMessage.describe()))
+	}
+	if clientMessage.messageID == NUM_MESSAGES {
+		s.doneClients++
+		if s.doneClients == NUM_CLIENTS {
+			// Tell the clients how many messages to expect once they have all been forwarded
+			flush := Message{messageID: s.forwarded, clock: s.lamportClock.Send(), kind: FLUSH_MESSAGE, vectorTimeStamp: s.vectorClock.Send()}
+			shivizLog.Log(0, flush.vectorTimeStamp, fmt.Sprintf("Server sends %s", flush.describe()))
+			s.forward(flush, 0)
+		}
+	}
+	return false
 }
 
 // Clients listen for broadcast messages from the server
 func (c *Client) clientListener(wg *sync.WaitGroup) {
 	defer wg.Done()
 
-	for {
-		select {
-		case msg := <-c.clientChannel:
-			// Update client Lamport clock when receiving a message
-			c.lamportClock = updateLamportClock(c.lamportClock, msg.clock)
-			fmt.Printf("(Lamport Clock of Client %d: %d) Client %d received Message %d from Client %d\n", c.clientID, c.lamportClock, c.clientID, msg.messageID, msg.senderID)
-		case <-c.closeChannel:
-			fmt.Printf("Client %d is done listening for Messages...\n", c.clientID)
-			return
+	for wireMessage := range c.transport.Receive() {
+		if !c.receive(wireMessage) {
+			break
+		}
+	}
+	fmt.Printf("Client %d is done listening for Messages...\n", c.clientID)
+}
+
+// Client puts a message from the server back in the order it was sent and handles every message that is
+// now in order, returning false once the server tells it to stop
+func (c *Client) receive(wireMessage transport.Message) bool {
+	ready, duplicate := c.inbox.Add(0, wireMessage.Seq, fromWire(wireMessage))
+	if duplicate {
+		fmt.Printf("\033[33m[Duplicate] Client %d received %s again and ignores it\033[0m\n", c.clientID, fromWire(wireMessage).describe())
+	}
+	for _, msg := range ready {
+		if !c.handle(msg) {
+			return false
+		}
+	}
+	return true
+}
+
+// Client handles a message from the server, returning false once the server tells it to stop
+func (c *Client) handle(msg Message) bool {
+	if msg.kind == CLOSE_MESSAGE {
+		return false
+	}
+	key := delivery.Key{Timestamp: msg.timestamp, Sender: msg.senderID}
+	shivizLog.Log(c.clientID, c.vectorClock.Merge(msg.vectorTimeStamp), fmt.Sprintf("Client %d receives %s", c.clientID, msg.describe()))
+	switch msg.kind {
+	case DATA_MESSAGE:
+		// Update client Lamport clock when receiving a message
+		receiveTime := c.lamportClock.Receive(msg.clock)
+		fmt.Printf("(Lamport Clock of Client %d: %d) Client %d received Message %d from Client %d\n", c.clientID, receiveTime, c.clientID, msg.messageID, msg.senderID)
+		c.deliver(c.holdBack.Add(key, msg))
+		// Acknowledge the message to every client, itself included, through the server
+		c.sendToServer(Message{senderID: msg.senderID, messageID: msg.messageID, kind: ACK_MESSAGE, timestamp: msg.timestamp, ackerID: c.clientID})
+	case ACK_MESSAGE:
+		c.lamportClock.Receive(msg.clock)
+		c.deliver(c.holdBack.Ack(key, msg.ackerID))
+	case FLUSH_MESSAGE:
+		c.lamportClock.Receive(msg.clock)
+		c.expected = msg.messageID
+	}
+	if c.expected >= 0 && !c.doneSent && len(c.deliveryLog) == c.expected {
+		c.sendToServer(Message{senderID: c.clientID, kind: DONE_MESSAGE})
+		c.doneSent = true
+	}
+	return true
+}
+
+// Client delivers messages released by its hold-back queue
+func (c *Client) deliver(ready []Message) {
+	for _, msg := range ready {
+		c.deliveryLog = append(c.deliveryLog, fmt.Sprintf("Message %d from Client %d (timestamp %d)", msg.messageID, msg.senderID, msg.timestamp))
+		fmt.Printf("\033[36m(Lamport Clock of Client %d: %d) Client %d delivers Message %d from Client %d (timestamp %d)\033[0m\n", c.clientID, c.lamportClock.Time(), c.clientID, msg.messageID, msg.senderID, msg.timestamp)
+	}
+}
+
+// Client prints a digest of its delivery order, which is the same at every client if the total order held
+func (c *Client) reportDeliveryLog() {
+	digest := fnv.New32a()
+	for _, entry := range c.deliveryLog {
+		fmt.Fprintln(digest, entry)
+	}
+	fmt.Printf("Client %d delivered %d messages, order digest %08x.\n", c.clientID, len(c.deliveryLog), digest.Sum32())
+}
+
+// Check that every client delivered the same messages in the same order
+func checkDeliveryLogs(clients []*Client) bool {
+	reference := clients[0]
+	for _, client := range clients[1:] {
+		for i := 0; i < len(reference.deliveryLog) || i < len(client.deliveryLog); i++ {
+			if i >= len(reference.deliveryLog) || i >= len(client.deliveryLog) || reference.deliveryLog[i] != client.deliveryLog[i] {
+				fmt.Printf("\033[31mTotal order violated: Client %d and Client %d differ at delivery %d (%s vs %s).\033[0m\n", reference.clientID, client.clientID, i+1, logEntry(reference.deliveryLog, i), logEntry(client.deliveryLog, i))
+				return false
+			}
 		}
 	}
+	fmt.Printf("\033[32mAll %d clients delivered the same %d messages in the same order.\033[0m\n", len(clients), len(reference.deliveryLog))
+	return true
+}
+
+func logEntry(log []string, i int) string {
+	if i < len(log) {
+		return log[i]
+	}
+	return "nothing"
+}
+
+// Creates the server
+func newServer(t transport.Transport, dropPolicy loss.DropPolicy) *Server {
+	return &Server{
+		transport:     t,
+		lamportClock:  clock.NewLamportClock(),
+		vectorClock:   clock.NewVectorClock(0, NUM_CLIENTS+1),
+		dropPolicy:    dropPolicy,
+		inbox:         delivery.NewFIFOQueue[Message](),
+		sent:          make([]int, NUM_CLIENTS+1),
+		doneListening: make(map[int]bool),
+	}
+}
+
+// Creates a client that is a member of the group of all clients, every one of which has to acknowledge a message
+func newClient(id int, t transport.Transport) *Client {
+	members := make([]int, NUM_CLIENTS)
+	for i := range members {
+		members[i] = i + 1
+	}
+	return &Client{
+		clientID:     id,
+		transport:    t,
+		lamportClock: clock.NewLamportClock(),
+		vectorClock:  clock.NewVectorClock(id, NUM_CLIENTS+1),
+		holdBack:     delivery.NewTotalOrderQueue[Message](members),
+		expected:     -1,
+		inbox:        delivery.NewFIFOQueue[Message](),
+	}
+}
+
+// Runs the server and every client in virtual time, with the engine handing every message to the
+// receiver's handler instead of a goroutine
+func simulate(engine *sim.Engine, network *netsim.Network, dropPolicy loss.DropPolicy) []*Client {
+	virtual := transport.NewVirtual(network)
+	server := newServer(nil, dropPolicy)
+	server.transport = virtual.Endpoint(0, func(msg transport.Message) {
+		if server.receive(msg) {
+			server.closeClients()
+		}
+	})
+	clients := []*Client{}
+	for i := 1; i <= NUM_CLIENTS; i++ {
+		client := newClient(i, nil)
+		client.transport = virtual.Endpoint(i, func(msg transport.Message) { client.receive(msg) })
+		clients = append(clients, client)
+		for messageID := 1; messageID <= NUM_MESSAGES; messageID++ {
+			messageID := messageID
+			engine.AfterFunc(time.Duration(messageID)*MESSAGE_DELAY, func() { client.sendData(messageID) })
+		}
+	}
+
+	start := time.Now()
+	events := engine.Run(0)
+	fmt.Printf("Simulated %v in %d events (seed %d) in %v.\n", engine.Elapsed(), events, SEED, time.Since(start).Round(time.Microsecond))
+	checkDeliveryLogs(clients)
+	return clients
 }
 
 func main() {
-	rand.Seed(time.Now().UnixNano())
-	// Get user input for number of clients
-	for {
-		fmt.Print("Enter the number of clients (at least 2): ")
-		fmt.Scan(&NUM_CLIENTS)
-		if NUM_CLIENTS < 2 {
-			fmt.Println("You need at least 2 clients to communicate.")
-		} else {
-			break
+	flag.StringVar(&SHIVIZ_FILE, "shiviz", "", "write a log for the ShiViz visualiser to this file")
+	drop := flag.String("drop", "fixed:0.5", loss.Usage)
+	netSpec := flag.String("net", "", netsim.Usage)
+	flag.StringVar(&ADDRESS, "addr", ADDRESS, "address the server listens on and the clients connect to in server and client mode")
+	flag.IntVar(&CLIENT_ID, "id", CLIENT_ID, "ID of the client in client mode")
+	flag.Int64Var(&SEED, "seed", 0, "seed of every random decision, taken from the clock when 0")
+	record := flag.String("record", "", "record every random decision to this JSON Lines file")
+	replay := flag.String("replay", "", "repeat the random decisions recorded in this file, as long as the run asks for the same ones")
+	flag.IntVar(&NUM_CLIENTS, "clients", 0, "number of clients, asked for when not given")
+	flag.IntVar(&NUM_MESSAGES, "messages", 0, "number of messages each client sends, -1 for infinite messages, asked for when not given")
+	flag.DurationVar(&MESSAGE_DELAY, "delay", MESSAGE_DELAY, "time between two messages of a client")
+	configFile := flag.String("config", "", config.Usage)
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] [server | client | sim] [flags]\n\nWithout a mode the server and every client run in this process. In sim mode they run in virtual time.\n\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	// Flags may also follow the mode
+	mode := flag.Arg(0)
+	if mode != "" {
+		flag.CommandLine.Parse(flag.Args()[1:])
+	}
+	if (mode != "" && mode != "server" && mode != "client" && mode != "sim") || flag.NArg() > 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err := config.Load(flag.CommandLine, *configFile); err != nil {
+		fmt.Println(err)
+		os.Exit(2)
+	}
+	// Every random decision comes from one source, so that a run can be repeated from its seed or its recording
+	random, err := chance.Open(SEED, *record, *replay)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(2)
+	}
+	defer random.Close()
+	SEED = random.Seed()
+	var engine *sim.Engine
+	scheduler := sim.RealTime()
+	if mode == "sim" {
+		engine = sim.NewEngine()
+		scheduler = engine
+	}
+	dropPolicy, err := loss.Parse(*drop, random.For("drop"))
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(2)
+	}
+	network, err := netsim.Parse(*netSpec, scheduler, random.For("net"))
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(2)
+	}
+	// Get user input for number of clients unless it was given
+	if NUM_CLIENTS == 0 {
+		for {
+			fmt.Print("Enter the number of clients (at least 2): ")
+			fmt.Scan(&NUM_CLIENTS)
+			if NUM_CLIENTS < 2 {
+				fmt.Println("You need at least 2 clients to communicate.")
+			} else {
+				break
+			}
 		}
+	} else if NUM_CLIENTS < 2 {
+		fmt.Println("You need at least 2 clients to communicate.")
+		os.Exit(2)
 	}
 
-	// Get user input for number of messages per client
-	for {
-		fmt.Print("Enter the number of messages each client will send (enter -1 for infinite messages): ")
-		fmt.Scan(&NUM_MESSAGES)
-		if NUM_MESSAGES == 0 {
-			fmt.Println("There is nothing to communicate. Please enter a valid number of messages.")
-		} else {
-			break
+	// Get user input for number of messages per client unless it was given
+	if NUM_MESSAGES == 0 {
+		for {
+			fmt.Print("Enter the number of messages each client will send (enter -1 for infinite messages): ")
+			fmt.Scan(&NUM_MESSAGES)
+			if NUM_MESSAGES == 0 {
+				fmt.Println("There is nothing to communicate. Please enter a valid number of messages.")
+			} else if NUM_MESSAGES < -1 {
+				fmt.Println("Invalid input. Please enter at least 1 or -1 for infinite messages.")
+			} else {
+				break
+			}
 		}
+	} else if NUM_MESSAGES < -1 {
+		fmt.Println("Invalid number of messages. Please give at least 1 or -1 for infinite messages.")
+		os.Exit(2)
+	}
+	if mode == "sim" && NUM_MESSAGES == -1 {
+		fmt.Println("A simulation needs a finite number of messages.")
+		os.Exit(2)
+	}
+	if SHIVIZ_FILE != "" {
+		hosts := []string{"Server"}
+		for i := 1; i <= NUM_CLIENTS; i++ {
+			hosts = append(hosts, fmt.Sprintf("Client%d", i))
+		}
+		var err error
+		shivizLog, err = shiviz.Create(SHIVIZ_FILE, hosts)
+		if err != nil {
+			fmt.Printf("Could not create ShiViz log: %v\n", err)
+		}
+		shivizLog.CloseOnInterrupt()
 	}
-	// Initialize server
-	server := Server{serverChannel: make(chan Message, 10), clientsArray: make([]*Client, NUM_CLIENTS), lamportClock: 0}
-	var wg sync.WaitGroup
 
-	// Initialize clients and add them to the server's clients array
-	for i := 0; i < NUM_CLIENTS; i++ {
-		client := Client{
-			clientID:      i + 1,
-			clientChannel: make(chan Message),
-			server:        &server,
-			closeChannel:  make(chan bool),
-			lamportClock:  0,
+	// Set up the processes that run here, connected in memory or over TCP
+	var server *Server
+	var clients []*Client
+	switch mode {
+	case "":
+		hub := transport.NewMemory()
+		server = newServer(transport.Simulate(hub.Endpoint(0), 0, network), dropPolicy)
+		for i := 1; i <= NUM_CLIENTS; i++ {
+			clients = append(clients, newClient(i, transport.Simulate(hub.Endpoint(i), i, network)))
+		}
+	case "server":
+		fmt.Printf("Server is waiting for %d clients on %s...\n", NUM_CLIENTS, ADDRESS)
+		serverTransport, err := transport.Listen(ADDRESS, NUM_CLIENTS)
+		if err != nil {
+			fmt.Printf("Could not listen on %s: %v\n", ADDRESS, err)
+			os.Exit(1)
+		}
+		defer serverTransport.Close()
+		server = newServer(transport.Simulate(serverTransport, 0, network), dropPolicy)
+	case "client":
+		if CLIENT_ID < 1 || CLIENT_ID > NUM_CLIENTS {
+			fmt.Printf("Client ID must be between 1 and %d.\n", NUM_CLIENTS)
+			os.Exit(2)
+		}
+		clientTransport, err := transport.Dial(ADDRESS, CLIENT_ID)
+		if err != nil {
+			fmt.Printf("Could not connect to the server at %s: %v\n", ADDRESS, err)
+			os.Exit(1)
 		}
-		server.clientsArray[i] = &client
+		defer clientTransport.Close()
+		clients = append(clients, newClient(CLIENT_ID, transport.Simulate(clientTransport, CLIENT_ID, network)))
+	case "sim":
+		simulate(engine, network, dropPolicy)
 	}
+	var wg sync.WaitGroup
 
 	// Start server listener in a goroutine
-	wg.Add(1)
-	go server.serverListener(&wg)
+	if server != nil {
+		wg.Add(1)
+		go server.serverListener(&wg)
+	}
 
-	for _, client := range server.clientsArray {
+	for _, client := range clients {
 		wg.Add(1)
 		go client.clientListener(&wg)
 		wg.Add(1)
@@ -179,5 +572,18 @@ func main() {
 
 	// Wait for all goroutines (clients and server) to complete
 	wg.Wait()
+	switch mode {
+	case "":
+		checkDeliveryLogs(clients)
+	case "client":
+		clients[0].reportDeliveryLog()
+	}
+	if shivizLog != nil {
+		if err := shivizLog.Close(); err != nil {
+			fmt.Printf("Could not write ShiViz log: %v\n", err)
+		} else {
+			fmt.Printf("ShiViz log written to %s.\n", SHIVIZ_FILE)
+		}
+	}
 	fmt.Println("All messages processed, program exiting.")
 }
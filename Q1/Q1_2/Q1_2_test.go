@@ -0,0 +1,48 @@
+package main
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/Jashveragiwala/Lamport-and-Vector-Clocks/chance"
+	"github.com/Jashveragiwala/Lamport-and-Vector-Clocks/loss"
+	"github.com/Jashveragiwala/Lamport-and-Vector-Clocks/netsim"
+	"github.com/Jashveragiwala/Lamport-and-Vector-Clocks/sim"
+)
+
+// Runs a simulation for every seed and checks that every client delivered every forwarded message in the same order
+func checkTotalOrder(t *testing.T, netSpec, dropSpec string) {
+	t.Helper()
+	NUM_CLIENTS, NUM_MESSAGES = 3, 10
+	for seed := int64(1); seed <= 10; seed++ {
+		engine := sim.NewEngine()
+		random := chance.New(seed)
+		network, err := netsim.Parse(netSpec, engine, random.For("net"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		dropPolicy, err := loss.Parse(dropSpec, random.For("drop"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		clients := simulate(engine, network, dropPolicy)
+		for _, client := range clients {
+			if client.expected < 0 || len(client.deliveryLog) != client.expected {
+				t.Fatalf("seed %d: Client %d delivered %d messages, want the %d forwarded", seed, client.clientID, len(client.deliveryLog), client.expected)
+			}
+			if !slices.Equal(client.deliveryLog, clients[0].deliveryLog) {
+				t.Fatalf("seed %d: Client %d delivered in a different order than Client %d", seed, client.clientID, clients[0].clientID)
+			}
+		}
+	}
+}
+
+// A client with a much slower link to the server must still deliver its own messages in the same order as the others
+func TestTotalOrderWithAsymmetricLatency(t *testing.T) {
+	checkTotalOrder(t, "latency=exponential:100ms;3->0:latency=exponential:3s", "never")
+}
+
+// Every link reorders and duplicates messages, which the numbering on every link has to undo
+func TestTotalOrderWithReorderingAndDuplicates(t *testing.T) {
+	checkTotalOrder(t, "latency=uniform:10ms:1500ms,reorder=1s,duplicate=0.3", "fixed:0.3")
+}
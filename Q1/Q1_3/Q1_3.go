@@ -1,10 +1,23 @@
 package main
 
 import (
+	"flag"
 	"fmt"
-	"math/rand"
+	"os"
+	"slices"
 	"sync"
 	"time"
+
+	"github.com/Jashveragiwala/Lamport-and-Vector-Clocks/chance"
+	"github.com/Jashveragiwala/Lamport-and-Vector-Clocks/clock"
+	"github.com/Jashveragiwala/Lamport-and-Vector-Clocks/config"
+	"github.com/Jashveragiwala/Lamport-and-Vector-Clocks/delivery"
+	"github.com/Jashveragiwala/Lamport-and-Vector-Clocks/loss"
+	"github.com/Jashveragiwala/Lamport-and-Vector-Clocks/netsim"
+	"github.com/Jashveragiwala/Lamport-and-Vector-Clocks/shiviz"
+	"github.com/Jashveragiwala/Lamport-and-Vector-Clocks/sim"
+	"github.com/Jashveragiwala/Lamport-and-Vector-Clocks/trace"
+	"github.com/Jashveragiwala/Lamport-and-Vector-Clocks/transport"
 )
 
 var wg sync.WaitGroup
@@ -13,22 +26,42 @@ type Message struct {
 	senderID        int
 	messageID       int
 	vectorTimeStamp []int
+	causalVector    []int // Broadcast counts used for causal delivery
 }
 
 type Client struct {
-	pID             int
-	clientChannel   chan Message
-	server          *Server
-	closeChannel    chan bool
-	readyChannel    chan int
-	vectorTimeStamp []int
+	pID          int
+	transport    transport.Transport
+	readyChannel chan int
+	vectorClock  *clock.VectorClock
+	holdBack     *delivery.CausalQueue[heldMessage]
+	stats        *deliveryStats
+	received     map[[2]int]bool // Sender and message ID of every broadcast received, to spot duplicates
 }
 
 type Server struct {
-	pID             int
-	serverChannel   chan Message
-	clientsArray    []*Client
-	vectorTimeStamp []int
+	pID          int
+	transport    transport.Transport
+	vectorClock  *clock.VectorClock
+	forwarded    []int // Number of messages forwarded from each client
+	dropPolicy   loss.DropPolicy
+	received     map[[2]int]bool // Sender and message ID of every message received, to spot duplicates
+	receivedFrom []int           // Number of messages received from each client
+	doneClients  int
+}
+
+// Message waiting in a client's hold-back queue
+type heldMessage struct {
+	message    Message
+	receivedAt time.Time
+}
+
+// Hold-back statistics of a client in causal delivery mode
+type deliveryStats struct {
+	heldBack      int
+	maxQueueDepth int
+	totalDelay    time.Duration
+	maxDelay      time.Duration
 }
 
 type Event struct {
@@ -37,6 +70,8 @@ type Event struct {
 	messageID       int
 	vectorTimeStamp []int
 	eventType       int
+	wallTime        time.Time
+	violation       bool // Receipt was a causality violation
 }
 
 var (
@@ -44,7 +79,18 @@ var (
 	NUM_MESSAGES  int
 	NUM_EVENTS    int
 	NUM_CLOCKS    int
-	MESSAGE_DELAY = 100
+	MESSAGE_DELAY = 100 * time.Millisecond
+
+	CAUSAL_DELIVERY bool
+	TRACE_FILE      string
+	SHIVIZ_FILE     string
+
+	ADDRESS   = "localhost:9000"
+	CLIENT_ID = 1
+	SEED      int64
+
+	shivizLog *shiviz.Logger                  // nil unless a ShiViz log was requested
+	scheduler sim.Scheduler  = sim.RealTime() // Virtual time in sim mode
 )
 
 const (
@@ -52,192 +98,554 @@ const (
 	SERVER_RECEIVE_EVENT   = 2
 	SERVER_BROADCAST_EVENT = 3
 	CLIENT_RECEIVE_EVENT   = 4
+	SERVER_DROP_EVENT      = 5
 )
 
-// Function to find the greaterimum of two integers
-func greater(a int, b int) int {
-	if a > b {
-		return a
-	}
-	return b
+// Kinds of messages on the wire
+const (
+	DATA_MESSAGE  = 1
+	CLOSE_MESSAGE = 2 // Server has broadcast every message, the client can stop listening
+)
+
+// Converts a message to its wire form
+func (msg Message) wire() transport.Message {
+	return transport.Message{Kind: DATA_MESSAGE, Sender: msg.senderID, MessageID: msg.messageID, Vector: msg.vectorTimeStamp, Causal: msg.causalVector}
 }
 
-// Function to merge two vector timestamps
-func mergeVectorClock(x []int, y []int, receiverID int) []int {
-	z := make([]int, len(x))
-	for i := range x {
-		z[i] = greater(x[i], y[i])
-	}
-	z[receiverID] += 1
-	return z
+// Converts a message from its wire form
+func fromWire(msg transport.Message) Message {
+	return Message{msg.Sender, msg.MessageID, msg.Vector, msg.Causal}
+}
+
+// Reports whether a message names a client and carries a vector timestamp and broadcast counts with an entry
+// for every process, since anything else off the wire would index past the receiver's own vectors
+func (msg Message) wellFormed() bool {
+	return msg.senderID >= 1 && msg.senderID <= NUM_CLIENTS && len(msg.vectorTimeStamp) == NUM_CLOCKS && len(msg.causalVector) == NUM_CLOCKS
 }
 
 func (c Client) prepMsgs() {
 	if NUM_MESSAGES == -1 {
 		// Infinite loop for unlimited messages
 		for messageID := 1; ; messageID++ {
-			time.Sleep(time.Duration(MESSAGE_DELAY) * time.Millisecond)
+			time.Sleep(MESSAGE_DELAY)
 			c.readyChannel <- messageID
 		}
 	} else {
 		// Send the specified number of messages
 		for i := 1; i <= NUM_MESSAGES; i++ {
-			time.Sleep(time.Duration(MESSAGE_DELAY) * time.Millisecond)
+			time.Sleep(MESSAGE_DELAY)
 			c.readyChannel <- i
 		}
 	}
 }
 
-func (s Server) serverListener(eventsChannel chan Event, pcvChannel chan string) {
+func (s *Server) serverListener(eventsChannel chan Event, pcvChannel chan string, concurrentChannel chan string) {
 	fmt.Println("Server is ready to receive messages...")
 
-	doneClients := 0
-	for {
-		clientMessage := <-s.serverChannel
-
-		if lesserVectorClock(clientMessage.vectorTimeStamp, s.vectorTimeStamp) {
-			pcv := fmt.Sprintf("\033[31m[Causality Violation] Server received Message %d from Client %d. Server VC: %v; Message VC: %v\033[0m\n", clientMessage.messageID, clientMessage.senderID, s.vectorTimeStamp, clientMessage.vectorTimeStamp)
-			pcvChannel <- pcv
+	for wireMessage := range s.transport.Receive() {
+		if s.receive(eventsChannel, pcvChannel, concurrentChannel, wireMessage) {
+			return
 		}
+	}
+	fmt.Println("Server lost the connection to the clients.")
+}
 
-		s.vectorTimeStamp = mergeVectorClock(s.vectorTimeStamp, clientMessage.vectorTimeStamp, s.pID)
-		fmt.Printf("\033[32m(Vector Clock of Server: %v) Server receives Message %d from Client %d\033[0m\n", s.vectorTimeStamp, clientMessage.messageID, clientMessage.senderID)
+// Server handles a message from a client, returning true once every message has been received
+func (s *Server) receive(eventsChannel chan Event, pcvChannel chan string, concurrentChannel chan string, wireMessage transport.Message) bool {
+	clientMessage := fromWire(wireMessage)
+	if !clientMessage.wellFormed() {
+		fmt.Fprintf(os.Stderr, "Server ignores a malformed message claiming to be Message %d from Client %d\n", clientMessage.messageID, clientMessage.senderID)
+		return false
+	}
+	if s.received[[2]int{clientMessage.senderID, clientMessage.messageID}] {
+		fmt.Printf("\033[33m[Duplicate] Server received Message %d from Client %d again and ignores it\033[0m\n", clientMessage.messageID, clientMessage.senderID)
+		return false
+	}
+	s.received[[2]int{clientMessage.senderID, clientMessage.messageID}] = true
+	s.receivedFrom[clientMessage.senderID]++
+
+	serverTime := s.vectorClock.Time()
+	violation := false
+	switch clock.Compare(clientMessage.vectorTimeStamp, serverTime) {
+	case clock.Before, clock.Equal:
+		violation = true
+		// The server has already seen events that causally follow this message
+		pcv := fmt.Sprintf("\033[31m[Causality Violation] Server received Message %d from Client %d. Server VC: %v; Message VC: %v\033[0m\n", clientMessage.messageID, clientMessage.senderID, serverTime, clientMessage.vectorTimeStamp)
+		fmt.Print(pcv)
+		pcvChannel <- pcv
+	case clock.Concurrent:
+		concurrent := fmt.Sprintf("\033[33m[Concurrent] Server received Message %d from Client %d. Server VC: %v; Message VC: %v\033[0m\n", clientMessage.messageID, clientMessage.senderID, serverTime, clientMessage.vectorTimeStamp)
+		fmt.Print(concurrent)
+		concurrentChannel <- concurrent
+	}
 
-		event := Event{clientMessage.senderID, 0, clientMessage.messageID, s.vectorTimeStamp, SERVER_RECEIVE_EVENT}
-		eventsChannel <- event
+	receiveTime := s.vectorClock.Merge(clientMessage.vectorTimeStamp)
+	fmt.Printf("\033[32m(Vector Clock of Server: %v) Server receives Message %d from Client %d\033[0m\n", receiveTime, clientMessage.messageID, clientMessage.senderID)
+	shivizLog.Log(s.pID, receiveTime, fmt.Sprintf("Server receives Message %d from Client %d", clientMessage.messageID, clientMessage.senderID))
 
-		s.vectorTimeStamp[s.pID] += 1
+	event := Event{clientMessage.senderID, 0, clientMessage.messageID, receiveTime, SERVER_RECEIVE_EVENT, scheduler.Now(), violation}
+	eventsChannel <- event
 
-		if rand.Intn(2) == 0 {
-			for receiverID := 1; receiverID <= NUM_CLIENTS; receiverID++ {
-				if receiverID != clientMessage.senderID {
-					serverBroadcastMessage := Message{clientMessage.senderID, clientMessage.messageID, s.vectorTimeStamp}
-					go s.serverSender(eventsChannel, serverBroadcastMessage, receiverID)
-				}
-			}
+	broadcastTime := s.vectorClock.Send()
+
+	receivers := []int{}
+	for receiverID := 1; receiverID <= NUM_CLIENTS; receiverID++ {
+		if receiverID != clientMessage.senderID {
+			receivers = append(receivers, receiverID)
+		}
+	}
+	dropped := s.dropPolicy.Drop(clientMessage.senderID, clientMessage.messageID, receivers)
+	lost := dropped
+	if CAUSAL_DELIVERY && len(dropped) > 0 {
+		// A client that misses a forwarded message would hold back the sender's later messages forever
+		dropped = receivers
+	}
+
+	if len(dropped) < len(receivers) {
+		if len(dropped) == 0 {
+			shivizLog.Log(s.pID, broadcastTime, fmt.Sprintf("Server broadcasts Message %d from Client %d", clientMessage.messageID, clientMessage.senderID))
 		} else {
-			fmt.Printf("\033[31m(Vector Clock of Server: %v) Server has dropped Message %d from Client %d\033[0m\n", s.vectorTimeStamp, clientMessage.messageID, clientMessage.senderID)
+			shivizLog.Log(s.pID, broadcastTime, fmt.Sprintf("Server broadcasts Message %d from Client %d, dropping it for Clients %v", clientMessage.messageID, clientMessage.senderID, dropped))
+		}
+		causalVector := clientMessage.causalVector
+		if CAUSAL_DELIVERY {
+			// Only forwarded messages count towards a client's broadcast sequence
+			s.forwarded[clientMessage.senderID]++
+			causalVector = append([]int(nil), clientMessage.causalVector...)
+			causalVector[clientMessage.senderID] = s.forwarded[clientMessage.senderID]
+		}
+		for _, receiverID := range receivers {
+			if !slices.Contains(dropped, receiverID) {
+				serverBroadcastMessage := Message{clientMessage.senderID, clientMessage.messageID, broadcastTime, causalVector}
+				s.serverSender(eventsChannel, serverBroadcastMessage, receiverID)
+			}
 		}
+	} else if len(lost) < len(receivers) {
+		fmt.Printf("\033[31m(Vector Clock of Server: %v) Server has dropped Message %d from Client %d for every client, as causal delivery cannot recover from losing it for Clients %v\033[0m\n", broadcastTime, clientMessage.messageID, clientMessage.senderID, lost)
+		shivizLog.Log(s.pID, broadcastTime, fmt.Sprintf("Server drops Message %d from Client %d for every client, widening the drop for Clients %v", clientMessage.messageID, clientMessage.senderID, lost))
+	} else {
+		fmt.Printf("\033[31m(Vector Clock of Server: %v) Server has dropped Message %d from Client %d\033[0m\n", broadcastTime, clientMessage.messageID, clientMessage.senderID)
+		shivizLog.Log(s.pID, broadcastTime, fmt.Sprintf("Server drops Message %d from Client %d", clientMessage.messageID, clientMessage.senderID))
+	}
+	for _, receiverID := range dropped {
+		if len(dropped) < len(receivers) {
+			fmt.Printf("\033[31m(Vector Clock of Server: %v) Server has dropped Message %d from Client %d for Client %d\033[0m\n", broadcastTime, clientMessage.messageID, clientMessage.senderID, receiverID)
+		}
+		eventsChannel <- Event{clientMessage.senderID, receiverID, clientMessage.messageID, broadcastTime, SERVER_DROP_EVENT, scheduler.Now(), false}
+	}
 
-		if clientMessage.messageID == NUM_MESSAGES {
-			doneClients++
-			if doneClients == NUM_CLIENTS {
-				fmt.Println("Server has received all messages.")
-				return
+	// Messages can arrive out of order, so a client is done once all of its messages have arrived
+	if s.receivedFrom[clientMessage.senderID] == NUM_MESSAGES {
+		s.doneClients++
+		if s.doneClients == NUM_CLIENTS {
+			fmt.Println("Server has received all messages.")
+			// Let the clients stop listening once every broadcast has been delivered
+			for clientID := 1; clientID <= NUM_CLIENTS; clientID++ {
+				s.transport.Flush(clientID)
+				if err := s.transport.Send(clientID, transport.Message{Kind: CLOSE_MESSAGE}); err != nil {
+					fmt.Fprintf(os.Stderr, "Could not tell Client %d to stop: %v\n", clientID, err)
+				}
+			}
+			// The server may close its transport next, so the network must have handed over every message
+			for clientID := 1; clientID <= NUM_CLIENTS; clientID++ {
+				s.transport.Flush(clientID)
 			}
+			return true
 		}
 	}
+	return false
 }
 
-func (s Server) serverSender(eventsChannel chan Event, serverBroadcastMessage Message, receiverID int) {
+// Server sends a broadcast to a client over the network
+func (s *Server) serverSender(eventsChannel chan Event, serverBroadcastMessage Message, receiverID int) {
 	fmt.Printf("\033[38;5;208m(Vector Clock of Server: %v) Server broadcasts Message %d from Client %d to Client %d\033[0m\n", serverBroadcastMessage.vectorTimeStamp, serverBroadcastMessage.messageID, serverBroadcastMessage.senderID, receiverID)
-	s.clientsArray[receiverID-1].clientChannel <- serverBroadcastMessage
-	event := Event{serverBroadcastMessage.senderID, receiverID, serverBroadcastMessage.messageID, s.vectorTimeStamp, SERVER_BROADCAST_EVENT}
+	if err := s.transport.Send(receiverID, serverBroadcastMessage.wire()); err != nil {
+		fmt.Fprintf(os.Stderr, "Could not send Message %d from Client %d to Client %d: %v\n", serverBroadcastMessage.messageID, serverBroadcastMessage.senderID, receiverID, err)
+	}
+	event := Event{serverBroadcastMessage.senderID, receiverID, serverBroadcastMessage.messageID, serverBroadcastMessage.vectorTimeStamp, SERVER_BROADCAST_EVENT, scheduler.Now(), false}
 	eventsChannel <- event
 }
 
-func (c Client) clientListenerSender(eventsChannel chan Event, pcvChannel chan string) {
+func (c Client) clientListenerSender(eventsChannel chan Event, pcvChannel chan string, concurrentChannel chan string) {
 	for {
 		select {
 		case messageID := <-c.readyChannel:
-			clientMessage := Message{c.pID, messageID, c.vectorTimeStamp}
-			fmt.Printf("\033[34m(Vector Clock of Client %d: %v) Client %d is sending Message %d to Server\033[0m\n", c.pID, c.vectorTimeStamp, c.pID, messageID)
-			c.server.serverChannel <- clientMessage
-			event := Event{clientMessage.senderID, 0, clientMessage.messageID, clientMessage.vectorTimeStamp, CLIENT_SEND_EVENT}
-			eventsChannel <- event
-
-		case serverBroadcastMessage := <-c.clientChannel:
-			if lesserVectorClock(serverBroadcastMessage.vectorTimeStamp, c.vectorTimeStamp) {
-				pcv := fmt.Sprintf("\033[31m[Causality Violation] Client %d receives Message %d from %d. Client %d's VC: %v; Message VC: %v\033[0m\n", c.pID, serverBroadcastMessage.messageID, serverBroadcastMessage.senderID, c.pID, c.vectorTimeStamp, serverBroadcastMessage.vectorTimeStamp)
-				fmt.Println(pcv)
-				pcvChannel <- pcv
-			}
-			c.vectorTimeStamp = mergeVectorClock(c.vectorTimeStamp, serverBroadcastMessage.vectorTimeStamp, c.pID)
-			fmt.Printf("(Vector Clock of Client %d: %v) Client %d receives Message %d from Client %d\n", c.pID, c.vectorTimeStamp, c.pID, serverBroadcastMessage.messageID, serverBroadcastMessage.senderID)
-			event := Event{serverBroadcastMessage.senderID, c.pID, serverBroadcastMessage.messageID, c.vectorTimeStamp, CLIENT_RECEIVE_EVENT}
-			eventsChannel <- event
+			c.send(eventsChannel, messageID)
 
-		case <-c.closeChannel:
-			fmt.Printf("Client %d has finished listening for messages.\n", c.pID)
-			return
+		case wireMessage, ok := <-c.transport.Receive():
+			if !ok || wireMessage.Kind == CLOSE_MESSAGE {
+				if !ok {
+					fmt.Printf("Client %d lost the connection to the server.\n", c.pID)
+				}
+				c.finish()
+				return
+			}
+			c.receive(eventsChannel, pcvChannel, concurrentChannel, wireMessage)
 
 		default:
 		}
 	}
 }
 
-// Function to check if vector timestamp x is less than y
-func lesserVectorClock(x []int, y []int) bool {
-	for i := range x {
-		if x[i] < y[i] {
-			return true
-		} else if x[i] > y[i] {
-			return false
+// Client sends the message with the given ID to the server
+func (c Client) send(eventsChannel chan Event, messageID int) {
+	clientMessage := Message{c.pID, messageID, c.vectorClock.Send(), c.holdBack.Delivered()}
+	fmt.Printf("\033[34m(Vector Clock of Client %d: %v) Client %d is sending Message %d to Server\033[0m\n", c.pID, clientMessage.vectorTimeStamp, c.pID, messageID)
+	shivizLog.Log(c.pID, clientMessage.vectorTimeStamp, fmt.Sprintf("Client %d sends Message %d to Server", c.pID, messageID))
+	if err := c.transport.Send(0, clientMessage.wire()); err != nil {
+		fmt.Fprintf(os.Stderr, "Client %d could not send Message %d: %v\n", c.pID, messageID, err)
+	}
+	event := Event{clientMessage.senderID, 0, clientMessage.messageID, clientMessage.vectorTimeStamp, CLIENT_SEND_EVENT, scheduler.Now(), false}
+	eventsChannel <- event
+}
+
+// Client handles a broadcast from the server
+func (c Client) receive(eventsChannel chan Event, pcvChannel chan string, concurrentChannel chan string, wireMessage transport.Message) {
+	serverBroadcastMessage := fromWire(wireMessage)
+	if !serverBroadcastMessage.wellFormed() {
+		fmt.Fprintf(os.Stderr, "Client %d ignores a malformed message claiming to be Message %d from Client %d\n", c.pID, serverBroadcastMessage.messageID, serverBroadcastMessage.senderID)
+		return
+	}
+	if c.received[[2]int{serverBroadcastMessage.senderID, serverBroadcastMessage.messageID}] {
+		fmt.Printf("\033[33m[Duplicate] Client %d received Message %d from Client %d again and ignores it\033[0m\n", c.pID, serverBroadcastMessage.messageID, serverBroadcastMessage.senderID)
+		return
+	}
+	c.received[[2]int{serverBroadcastMessage.senderID, serverBroadcastMessage.messageID}] = true
+	if CAUSAL_DELIVERY {
+		c.causalReceive(eventsChannel, serverBroadcastMessage)
+		return
+	}
+	clientTime := c.vectorClock.Time()
+	violation := false
+	switch clock.Compare(serverBroadcastMessage.vectorTimeStamp, clientTime) {
+	case clock.Before, clock.Equal:
+		violation = true
+		// The client has already seen events that causally follow this message
+		pcv := fmt.Sprintf("\033[31m[Causality Violation] Client %d receives Message %d from %d. Client %d's VC: %v; Message VC: %v\033[0m\n", c.pID, serverBroadcastMessage.messageID, serverBroadcastMessage.senderID, c.pID, clientTime, serverBroadcastMessage.vectorTimeStamp)
+		fmt.Print(pcv)
+		pcvChannel <- pcv
+	case clock.Concurrent:
+		concurrent := fmt.Sprintf("\033[33m[Concurrent] Client %d receives Message %d from %d. Client %d's VC: %v; Message VC: %v\033[0m\n", c.pID, serverBroadcastMessage.messageID, serverBroadcastMessage.senderID, c.pID, clientTime, serverBroadcastMessage.vectorTimeStamp)
+		fmt.Print(concurrent)
+		concurrentChannel <- concurrent
+	}
+	c.deliver(eventsChannel, serverBroadcastMessage, violation)
+}
+
+// Client stops listening and reports on its hold-back queue
+func (c Client) finish() {
+	if CAUSAL_DELIVERY {
+		c.reportHoldBack()
+	}
+	fmt.Printf("Client %d has finished listening for messages.\n", c.pID)
+}
+
+// Client merges a delivered broadcast into its vector clock
+func (c Client) deliver(eventsChannel chan Event, serverBroadcastMessage Message, violation bool) {
+	receiveTime := c.vectorClock.Merge(serverBroadcastMessage.vectorTimeStamp)
+	fmt.Printf("(Vector Clock of Client %d: %v) Client %d receives Message %d from Client %d\n", c.pID, receiveTime, c.pID, serverBroadcastMessage.messageID, serverBroadcastMessage.senderID)
+	shivizLog.Log(c.pID, receiveTime, fmt.Sprintf("Client %d receives Message %d from Client %d", c.pID, serverBroadcastMessage.messageID, serverBroadcastMessage.senderID))
+	event := Event{serverBroadcastMessage.senderID, c.pID, serverBroadcastMessage.messageID, receiveTime, CLIENT_RECEIVE_EVENT, scheduler.Now(), violation}
+	eventsChannel <- event
+}
+
+// Client buffers a broadcast until every message it causally depends on has been delivered
+func (c Client) causalReceive(eventsChannel chan Event, serverBroadcastMessage Message) {
+	ready := c.holdBack.Add(serverBroadcastMessage.senderID, serverBroadcastMessage.causalVector, heldMessage{serverBroadcastMessage, scheduler.Now()})
+	if len(ready) == 0 {
+		depth := c.holdBack.Len()
+		c.stats.heldBack++
+		if depth > c.stats.maxQueueDepth {
+			c.stats.maxQueueDepth = depth
 		}
+		fmt.Printf("\033[35mClient %d holds back Message %d from Client %d (causal vector %v, delivered %v, queue depth %d)\033[0m\n", c.pID, serverBroadcastMessage.messageID, serverBroadcastMessage.senderID, serverBroadcastMessage.causalVector, c.holdBack.Delivered(), depth)
+		return
+	}
+	// The received message is delivered first, then any held back messages it unblocked
+	for i, h := range ready {
+		if i > 0 {
+			delay := scheduler.Now().Sub(h.receivedAt)
+			c.stats.totalDelay += delay
+			if delay > c.stats.maxDelay {
+				c.stats.maxDelay = delay
+			}
+			fmt.Printf("\033[35mClient %d releases Message %d from Client %d after %v in the hold-back queue\033[0m\n", c.pID, h.message.messageID, h.message.senderID, delay.Round(time.Microsecond))
+		}
+		c.deliver(eventsChannel, h.message, false)
 	}
-	return false
 }
 
-func main() {
-	var err error
-	// Prompt for number of clients
-	for {
-		fmt.Print("Enter the number of clients (minimum 2): ")
-		_, err = fmt.Scan(&NUM_CLIENTS)
-		if err != nil || NUM_CLIENTS < 2 {
-			fmt.Println("Invalid input. Please enter at least 2 clients.")
+// Client prints the statistics of its hold-back queue
+func (c Client) reportHoldBack() {
+	average := time.Duration(0)
+	if c.stats.heldBack > 0 {
+		average = c.stats.totalDelay / time.Duration(c.stats.heldBack)
+	}
+	fmt.Printf("\033[35mClient %d held back %d messages (max queue depth %d, average delay %v, max delay %v); %d still undelivered.\033[0m\n", c.pID, c.stats.heldBack, c.stats.maxQueueDepth, average.Round(time.Microsecond), c.stats.maxDelay.Round(time.Microsecond), c.holdBack.Len())
+}
+
+// Converts an event into its trace record, seen from the process it happened at
+func (e Event) record() trace.Event {
+	record := trace.Event{Sender: e.senderID, MessageID: e.messageID, Vector: e.vectorTimeStamp, Time: e.wallTime, Violation: e.violation}
+	switch e.eventType {
+	case CLIENT_SEND_EVENT:
+		record.Process, record.Peer, record.Type = e.senderID, 0, trace.ClientSend
+	case SERVER_RECEIVE_EVENT:
+		record.Process, record.Peer, record.Type = 0, e.senderID, trace.ServerReceive
+	case SERVER_BROADCAST_EVENT:
+		record.Process, record.Peer, record.Type = 0, e.receiverID, trace.ServerBroadcast
+	case CLIENT_RECEIVE_EVENT:
+		record.Process, record.Peer, record.Type = e.receiverID, 0, trace.ClientReceive
+	case SERVER_DROP_EVENT:
+		record.Process, record.Peer, record.Type = 0, e.receiverID, trace.ServerDrop
+	}
+	return record
+}
+
+// Drains the events channel, writing every event to the trace file if one was requested
+func traceWriter(eventsChannel chan Event, done chan bool) {
+	var writer *trace.Writer
+	if TRACE_FILE != "" {
+		var err error
+		writer, err = trace.Create(TRACE_FILE)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Could not create trace file: %v\n", err)
+		}
+	}
+	for event := range eventsChannel {
+		if writer == nil {
 			continue
 		}
-		break
+		if err := writer.Write(event.record()); err != nil {
+			fmt.Fprintf(os.Stderr, "Could not write trace event: %v\n", err)
+		}
 	}
+	if writer != nil {
+		if err := writer.Close(); err != nil {
+			fmt.Fprintf(os.Stderr, "Could not write trace file: %v\n", err)
+		} else {
+			fmt.Printf("Trace written to %s.\n", TRACE_FILE)
+		}
+	}
+	done <- true
+}
 
-	// Prompt for number of messages
-	for {
-		fmt.Print("Enter the number of messages per client (-1 for infinte number of messages): ")
-		_, err = fmt.Scan(&NUM_MESSAGES)
-		if err != nil || NUM_MESSAGES < -1 {
-			fmt.Println("Invalid input. Please enter at least 1 or -1 for infinite messages.")
-			continue
+// Creates the server
+func newServer(t transport.Transport, dropPolicy loss.DropPolicy) *Server {
+	return &Server{0, t, clock.NewVectorClock(0, NUM_CLOCKS), make([]int, NUM_CLOCKS), dropPolicy, make(map[[2]int]bool), make([]int, NUM_CLOCKS), 0}
+}
+
+// Creates a client with its own vector clock
+func newClient(id int, t transport.Transport) *Client {
+	return &Client{id, t, make(chan int), clock.NewVectorClock(id, NUM_CLOCKS), delivery.NewCausalQueue[heldMessage](id, NUM_CLOCKS), &deliveryStats{}, make(map[[2]int]bool)}
+}
+
+// Runs the server and every client in virtual time, with the engine handing every message to the
+// handler of its receiver and sending each client's messages every MESSAGE_DELAY
+func simulate(engine *sim.Engine, network *netsim.Network, dropPolicy loss.DropPolicy, eventsChannel chan Event, pcvChannel chan string, concurrentChannel chan string) {
+	virtual := transport.NewVirtual(network)
+	server := newServer(nil, dropPolicy)
+	server.transport = virtual.Endpoint(server.pID, func(msg transport.Message) {
+		server.receive(eventsChannel, pcvChannel, concurrentChannel, msg)
+	})
+	clients := []*Client{}
+	for i := 1; i <= NUM_CLIENTS; i++ {
+		client := newClient(i, nil)
+		client.transport = virtual.Endpoint(i, func(msg transport.Message) {
+			// The simulation ends once every message has been delivered, so there is nothing to stop
+			if msg.Kind != CLOSE_MESSAGE {
+				client.receive(eventsChannel, pcvChannel, concurrentChannel, msg)
+			}
+		})
+		clients = append(clients, client)
+		for messageID := 1; messageID <= NUM_MESSAGES; messageID++ {
+			messageID := messageID
+			engine.AfterFunc(time.Duration(messageID)*MESSAGE_DELAY, func() { client.send(eventsChannel, messageID) })
+		}
+	}
+
+	start := time.Now()
+	events := engine.Run(0)
+	for _, client := range clients {
+		client.finish()
+	}
+	fmt.Printf("Simulated %v in %d events (seed %d) in %v.\n", engine.Elapsed(), events, SEED, time.Since(start).Round(time.Microsecond))
+}
+
+func main() {
+	flag.BoolVar(&CAUSAL_DELIVERY, "causal", false, "deliver broadcasts in causal order using a hold-back queue; a message the -drop policy drops for some clients is then dropped for every client")
+	flag.StringVar(&TRACE_FILE, "trace", "", "write every send and receive event to this JSON Lines file")
+	flag.StringVar(&SHIVIZ_FILE, "shiviz", "", "write a log for the ShiViz visualiser to this file")
+	drop := flag.String("drop", "fixed:0.5", loss.Usage)
+	netSpec := flag.String("net", "", netsim.Usage)
+	flag.StringVar(&ADDRESS, "addr", ADDRESS, "address the server listens on and the clients connect to in server and client mode")
+	flag.IntVar(&CLIENT_ID, "id", CLIENT_ID, "ID of the client in client mode")
+	flag.Int64Var(&SEED, "seed", 0, "seed of every random decision, taken from the clock when 0")
+	record := flag.String("record", "", "record every random decision to this JSON Lines file")
+	replay := flag.String("replay", "", "repeat the random decisions recorded in this file, as long as the run asks for the same ones")
+	flag.IntVar(&NUM_CLIENTS, "clients", 0, "number of clients, asked for when not given")
+	flag.IntVar(&NUM_MESSAGES, "messages", 0, "number of messages each client sends, -1 for infinite messages, asked for when not given")
+	flag.DurationVar(&MESSAGE_DELAY, "delay", MESSAGE_DELAY, "time between two messages of a client")
+	configFile := flag.String("config", "", config.Usage)
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] [server | client | sim] [flags]\n\nWithout a mode the server and every client run in this process. In sim mode they run in virtual time.\n\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	// Flags may also follow the mode
+	mode := flag.Arg(0)
+	if mode != "" {
+		flag.CommandLine.Parse(flag.Args()[1:])
+	}
+	if (mode != "" && mode != "server" && mode != "client" && mode != "sim") || flag.NArg() > 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err := config.Load(flag.CommandLine, *configFile); err != nil {
+		fmt.Println(err)
+		os.Exit(2)
+	}
+	// Every random decision comes from one source, so that a run can be repeated from its seed or its recording
+	random, err := chance.Open(SEED, *record, *replay)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(2)
+	}
+	defer random.Close()
+	SEED = random.Seed()
+	var engine *sim.Engine
+	if mode == "sim" {
+		engine = sim.NewEngine()
+		scheduler = engine
+	}
+	dropPolicy, err := loss.Parse(*drop, random.For("drop"))
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(2)
+	}
+	network, err := netsim.Parse(*netSpec, scheduler, random.For("net"))
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(2)
+	}
+
+	// Prompt for number of clients unless it was given
+	if NUM_CLIENTS == 0 {
+		for {
+			fmt.Print("Enter the number of clients (minimum 2): ")
+			_, err = fmt.Scan(&NUM_CLIENTS)
+			if err != nil || NUM_CLIENTS < 2 {
+				fmt.Println("Invalid input. Please enter at least 2 clients.")
+				continue
+			}
+			break
+		}
+	} else if NUM_CLIENTS < 2 {
+		fmt.Println("Invalid number of clients. Please give at least 2 clients.")
+		os.Exit(2)
+	}
+
+	// Prompt for number of messages unless it was given
+	if NUM_MESSAGES == 0 {
+		for {
+			fmt.Print("Enter the number of messages per client (-1 for infinte number of messages): ")
+			_, err = fmt.Scan(&NUM_MESSAGES)
+			if err != nil || NUM_MESSAGES < -1 {
+				fmt.Println("Invalid input. Please enter at least 1 or -1 for infinite messages.")
+				continue
+			}
+			break
 		}
-		break
+	} else if NUM_MESSAGES < -1 {
+		fmt.Println("Invalid number of messages. Please give at least 1 or -1 for infinite messages.")
+		os.Exit(2)
+	}
+
+	if mode == "sim" && NUM_MESSAGES == -1 {
+		fmt.Println("A simulation needs a finite number of messages.")
+		os.Exit(2)
 	}
 
 	NUM_CLOCKS = NUM_CLIENTS + 1 // One for each client and one for the server
 
+	if SHIVIZ_FILE != "" {
+		hosts := []string{"Server"}
+		for i := 1; i <= NUM_CLIENTS; i++ {
+			hosts = append(hosts, fmt.Sprintf("Client%d", i))
+		}
+		shivizLog, err = shiviz.Create(SHIVIZ_FILE, hosts)
+		if err != nil {
+			fmt.Printf("Could not create ShiViz log: %v\n", err)
+		}
+		shivizLog.CloseOnInterrupt()
+	}
+
 	if NUM_MESSAGES != -1 {
 		NUM_EVENTS = (2 + 2*(NUM_CLIENTS-1)) * NUM_CLIENTS * NUM_MESSAGES // Number of events
 	} else {
 		NUM_EVENTS = (2 + 2*(NUM_CLIENTS-1)) * NUM_CLIENTS * 100 // Assuming a large constant for infinite messages
 	}
 
-	clientArray := []*Client{}
-	vectorClock := make([]int, NUM_CLOCKS)
-	server := Server{0, make(chan Message), clientArray, vectorClock}
-
-	for i := 1; i <= NUM_CLIENTS; i++ {
-		client := Client{i, make(chan Message), &server, make(chan bool), make(chan int), make([]int, NUM_CLOCKS)} // Initialize client's vector clock as a slice
-		server.clientsArray = append(server.clientsArray, &client)
-	}
-
 	eventsChannel := make(chan Event, NUM_EVENTS)
 	pcvChannel := make(chan string, NUM_EVENTS)
+	concurrentChannel := make(chan string, NUM_EVENTS)
+
+	traceDone := make(chan bool)
+	go traceWriter(eventsChannel, traceDone)
+
+	// Set up the processes that run here, connected in memory or over TCP
+	var server *Server
+	clientArray := []*Client{}
+	switch mode {
+	case "":
+		hub := transport.NewMemory()
+		server = newServer(transport.Simulate(hub.Endpoint(0), 0, network), dropPolicy)
+		for i := 1; i <= NUM_CLIENTS; i++ {
+			clientArray = append(clientArray, newClient(i, transport.Simulate(hub.Endpoint(i), i, network)))
+		}
+	case "server":
+		fmt.Printf("Server is waiting for %d clients on %s...\n", NUM_CLIENTS, ADDRESS)
+		serverTransport, err := transport.Listen(ADDRESS, NUM_CLIENTS)
+		if err != nil {
+			fmt.Printf("Could not listen on %s: %v\n", ADDRESS, err)
+			os.Exit(1)
+		}
+		defer serverTransport.Close()
+		server = newServer(transport.Simulate(serverTransport, 0, network), dropPolicy)
+	case "client":
+		if CLIENT_ID < 1 || CLIENT_ID > NUM_CLIENTS {
+			fmt.Printf("Client ID must be between 1 and %d.\n", NUM_CLIENTS)
+			os.Exit(2)
+		}
+		clientTransport, err := transport.Dial(ADDRESS, CLIENT_ID)
+		if err != nil {
+			fmt.Printf("Could not connect to the server at %s: %v\n", ADDRESS, err)
+			os.Exit(1)
+		}
+		defer clientTransport.Close()
+		clientArray = append(clientArray, newClient(CLIENT_ID, transport.Simulate(clientTransport, CLIENT_ID, network)))
+	case "sim":
+		simulate(engine, network, dropPolicy, eventsChannel, pcvChannel, concurrentChannel)
+	}
 
 	// Start all client and server goroutines with wait group
-	for _, client := range server.clientsArray {
+	for _, client := range clientArray {
 		wg.Add(1)
 		go func(c *Client) {
 			defer wg.Done()
-			c.clientListenerSender(eventsChannel, pcvChannel)
+			c.clientListenerSender(eventsChannel, pcvChannel, concurrentChannel)
 		}(client)
 	}
 
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		server.serverListener(eventsChannel, pcvChannel)
-	}()
+	if server != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			server.serverListener(eventsChannel, pcvChannel, concurrentChannel)
+		}()
+	}
 
-	for _, client := range server.clientsArray {
+	for _, client := range clientArray {
 		wg.Add(1)
 		go func(c *Client) {
 			defer wg.Done()
@@ -246,8 +654,18 @@ func main() {
 	}
 
 	wg.Wait()
+	fmt.Printf("Detected %d causality violations and %d concurrent receipts.\n", len(pcvChannel), len(concurrentChannel))
 	close(pcvChannel)
+	close(concurrentChannel)
 	close(eventsChannel)
+	<-traceDone
+	if shivizLog != nil {
+		if err := shivizLog.Close(); err != nil {
+			fmt.Printf("Could not write ShiViz log: %v\n", err)
+		} else {
+			fmt.Printf("ShiViz log written to %s.\n", SHIVIZ_FILE)
+		}
+	}
 
 	fmt.Println("Program has finished execution.")
 }
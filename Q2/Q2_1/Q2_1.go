@@ -1,146 +1,527 @@
 package main
 
 import (
+	"flag"
 	"fmt"
-	"math/rand"
 	"os"
+	"slices"
+	"strings"
 	"sync"
 	"time"
+
+	"github.com/Jashveragiwala/Lamport-and-Vector-Clocks/chance"
+	"github.com/Jashveragiwala/Lamport-and-Vector-Clocks/clock"
+	"github.com/Jashveragiwala/Lamport-and-Vector-Clocks/config"
+	"github.com/Jashveragiwala/Lamport-and-Vector-Clocks/detector"
+	"github.com/Jashveragiwala/Lamport-and-Vector-Clocks/faults"
+	"github.com/Jashveragiwala/Lamport-and-Vector-Clocks/netsim"
+	"github.com/Jashveragiwala/Lamport-and-Vector-Clocks/shiviz"
+	"github.com/Jashveragiwala/Lamport-and-Vector-Clocks/sim"
 )
 
 type Process struct {
-	id      int
-	status  int // 1: active, 0: crashed
-	data    int // local version of the data structure
-	lock    sync.Mutex
-	elected bool
-	ring    []int
+	id            int
+	status        int // 1: active, 0: crashed
+	data          int // local version of the data structure
+	lock          sync.Mutex
+	elected       bool
+	ring          []int
+	coordinator   int // ID of the coordinator this process knows of
+	term          int // Highest election term this process has seen, see terms.go
+	initiator     int // Process that started the election of term, 0 once the process knows its coordinator
+	electingSince time.Time
+	coordTerm     int         // Term in which the coordinator this process knows of was elected
+	view          int         // Coordinator the process follows as the split brain watch sees it, 0 if none, see partitions.go
+	announced     map[int]int // Latest announcement or membership update this process has received from each announcer
+	announcements int         // Coordinator announcements this process has started
+	inbox         *mailbox    // Messages to the process, handled one at a time by its own goroutine
+	crashedAt     time.Time
+
+	detectors       map[int]detector.Detector // Failure detector of each process this one monitors, by ID
+	lastHeartbeat   map[int]time.Time
+	suspected       bool // Whether the process suspects the coordinator it knows of
+	suspicions      int
+	falseSuspicions int // Suspicions of a coordinator that had not crashed, only known to the report at the end
+	splices         int // Processes this one has spliced out of the ring after them
+	falseSplices    int
+
+	electing   bool         // Whether the process is running a Bully election
+	answered   bool         // Whether a process with a higher ID has answered its Bully election
+	bullyRound int          // Bully elections the process has started, to match answers and timers with them
+	electors   map[int]bool // Processes whose Bully elections this one has answered since it last learned a coordinator
+
+	participated bool // Whether the process has passed on or stood as a candidate in this Chang-Roberts or Hirschberg-Sinclair election
+	phase        int  // Phase of its Hirschberg-Sinclair candidacy
+	replies      int  // Replies that have come back to it in this phase
+
+	votedFor      int          // Raft candidate this process voted for in its term, 0 if none
+	voters        map[int]bool // Processes that voted for it as a Raft candidate in its term
+	electionTimer int          // Raft election timers the process has started, to ignore all but the latest
+
+	failovers       int // Times this process learned a new coordinator after the one it knew of crashed
+	failoverTime    time.Duration
+	longestFailover time.Duration
+
+	handovers map[int]*handover // Messages passed round the ring and not yet acknowledged, by attempt
+	attempt   int               // Number of the latest message passed round the ring, to match it with its acknowledgement
+
+	recoveries   int  // Times the process has recovered from a crash
+	joins        int  // Times the process has asked to join the ring, after a recovery or when it was left out of it
+	joining      bool // Recovered or left out, and not yet let back into the ring by a coordinator, or under Raft not yet heard from a leader
+	joiningSince time.Time
+	joinRequests int
+	admitted     map[int]int // Latest join request of each process this one has let back into the ring as coordinator
+	joinedIn     map[int]int // Membership update that last let each of them back in
+
+	vectorClock *clock.VectorClock // Index id-1, only used for the ShiViz log
 }
 
 var processes []*Process
-var wg sync.WaitGroup
-var coordinator *Process
-var electionInProgress = false // Flag to indicate if an election is in progress
+var SHIVIZ_FILE string
+var shivizLog *shiviz.Logger // nil unless a ShiViz log was requested
+var SIMULATE bool
+var SEED int64
+var HEARTBEAT_INTERVAL = 4 * time.Second
+var ACK_TIMEOUT = time.Second
+var JOIN_ATTEMPTS = 3 // Heartbeat intervals a recovered process asks to join before it starts an election itself
+var DETECTOR = "timeout:10s"
+var newDetector detector.New
+var ELECTION = "ring" // Election algorithm, one of ELECTIONS
+var ELECTIONS = []string{"ring", "bully", "chang-roberts", "hirschberg-sinclair", "raft"}
+var RAFT_TIMEOUT = 10 * time.Second // Shortest election timeout of a Raft follower
+var NET string
+var network *netsim.Network // Carries every message between two processes
+var CRASHES = "random@10s/20s"
+var recovering bool // Whether the crash schedule recovers processes, so that the run goes on once all have crashed
+var DURATION time.Duration
+var scheduler sim.Scheduler = sim.RealTime() // Virtual time in a simulation
+var random *chance.Source                    // Makes every random decision
+var finished = make(chan bool)               // Closed once the run is over
+var endOnce sync.Once
+var coordinator *Process   // The coordinator as the crash schedule and the data changes see it
+var coordinatorCrashes int // Crashes of the coordinator as the environment sees it, for the report at the end
 var electionMutex sync.Mutex
 
+// Counts of the election messages for the report at the end and for each election as it ends
+var statsMutex sync.Mutex
+var electionsStarted, electionsHeld int
+var lastTermEnded int
+var sentByKind = make(map[int]int)
+var bytesByKind = make(map[int]int)
+var electionSent, electionBytes int // Messages and bytes since the last election ended
+
+// Every heartbeat interval the coordinator sends its data to all processes and the others check on the coordinator and
+// send a heartbeat to the process before them in the ring
 func (p *Process) run() {
-	defer wg.Done()
-	for {
-		if p.status == 1 {
-			if p.id == coordinator.id {
-				// The coordinator periodically sends its data to all processes every 4 seconds
-				time.Sleep(4 * time.Second)
-				if p.status == 1 { // Ensure the coordinator is still active
-					p.sendDataToProcesses()
-				}
-			} else {
-				// Check if it's been more than 4 seconds since the last data was received
-				time.Sleep(4 * time.Second)
-				p.checkCoordinatorStatus()
+	p.after(HEARTBEAT_INTERVAL, Message{kind: TICK_MESSAGE, attempt: p.recoveries})
+}
+
+// Function for a process to handle one message
+func (p *Process) handle(msg Message) {
+	if !p.active() {
+		return // A crashed process does nothing more
+	}
+	switch msg.kind {
+	case TICK_MESSAGE:
+		if msg.attempt != p.recoveries {
+			return // The heartbeats from before the process crashed are over
+		}
+		if ELECTION == "raft" {
+			if p.leads() {
+				p.sendAppendEntries()
 			}
+		} else if p.joining {
+			p.requestJoin()
+		} else if p.id == p.coordinator {
+			p.sendDataToProcesses()
+			p.checkSuccessorStatus()
+		} else {
+			p.sendAlive()
+			p.checkCoordinatorStatus()
+			p.checkSuccessorStatus()
+		}
+		p.run()
+	case RECOVER_MESSAGE:
+		p.rejoin()
+	case JOIN_MESSAGE:
+		if p.id == p.coordinator && !p.joining {
+			p.admit(msg)
+		}
+	case LEAVE_MESSAGE:
+		if p.id == p.coordinator && !p.joining {
+			p.removeFromRing(msg)
+		}
+	case ALIVE_MESSAGE:
+		p.heartbeat(msg.from)
+	case DATA_MESSAGE:
+		p.heartbeat(msg.from) // The data of the coordinator is also its heartbeat
+		p.receiveData(msg)
+	case ELECTION_MESSAGE:
+		if ELECTION == "bully" {
+			p.receiveBullyElection(msg)
+			return
+		}
+		p.send(msg.from, Message{kind: ACK_MESSAGE, attempt: msg.attempt, data: msg.kind}, "")
+		if p.joinElection(msg) {
+			p.receiveElection(msg)
+		}
+	case REPLY_MESSAGE:
+		p.send(msg.from, Message{kind: ACK_MESSAGE, attempt: msg.attempt, data: msg.kind}, "")
+		if p.joinElection(msg) {
+			p.receiveReply(msg)
+		}
+	case COORDINATOR_MESSAGE, MEMBERSHIP_MESSAGE:
+		if msg.kind == COORDINATOR_MESSAGE && ELECTION == "bully" {
+			p.receiveBullyCoordinator(msg)
+			return
+		}
+		p.send(msg.from, Message{kind: ACK_MESSAGE, attempt: msg.attempt, data: msg.kind}, "")
+		p.receiveCoordinator(msg)
+	case OK_MESSAGE:
+		p.receiveOK(msg)
+	case OK_TIMEOUT_MESSAGE, COORDINATOR_TIMEOUT_MESSAGE:
+		p.bullyTimeout(msg)
+	case REQUEST_VOTE_MESSAGE:
+		p.receiveRequestVote(msg)
+	case VOTE_MESSAGE:
+		p.receiveVote(msg)
+	case APPEND_ENTRIES_MESSAGE:
+		p.receiveAppendEntries(msg)
+	case REJECT_MESSAGE:
+		p.raftTerm(msg.term, msg.from)
+	case ELECTION_TIMEOUT_MESSAGE:
+		p.raftTimeout(msg)
+	case ACK_MESSAGE:
+		delete(p.handovers, msg.attempt)
+	case ACK_TIMEOUT_MESSAGE:
+		if h := p.handovers[msg.attempt]; h != nil {
+			delete(p.handovers, msg.attempt)
+			fmt.Printf("\033[32mProcess %d got no answer from Process %d, passing the %s on.\033[0m\n", p.id, h.ring[h.next], kindName(h.msg))
+			p.passAlong(h.msg, h.ring, h.next+1)
 		}
 	}
 }
 
-// Function for the coordinator to send data to all processes
+// Function for the coordinator to send data to all processes. Those outside its ring get it too, so that once a
+// partition heals the coordinators of its groups hear of each other
 func (p *Process) sendDataToProcesses() {
 	p.lock.Lock()
-	defer p.lock.Unlock()
+	data := p.data
+	p.lock.Unlock()
 	for _, proc := range processes {
-		if proc.status == 1 && proc.id != p.id {
-			fmt.Printf("Coordinator %d is sending data %d to Process %d.\n", p.id, p.data, proc.id)
-			proc.lock.Lock()
-			if proc.status == 1 {
-				proc.data = p.data
-				fmt.Printf("Process %d updated its data to: %d (received from Coordinator %d)\n", proc.id, proc.data, p.id)
-			}
-			proc.lock.Unlock()
+		id := proc.id
+		if id == p.id {
+			continue
 		}
+		fmt.Printf("Coordinator %d is sending data %d to Process %d.\n", p.id, data, id)
+		p.send(id, Message{kind: DATA_MESSAGE, data: data, term: p.coordTerm}, fmt.Sprintf("Coordinator %d sends data %d to Process %d", p.id, data, id))
 	}
 }
 
-// Function to check the status of the coordinator and initiate an election if necessary
-func (p *Process) checkCoordinatorStatus() {
-	if coordinator.status == 0 {
-		electionMutex.Lock()
-		if !electionInProgress {
-			electionInProgress = true
-			fmt.Printf("\033[32mProcess %d detects that Coordinator %d has crashed, initiating election.\033[0m\n", p.id, coordinator.id)
-			go p.initiateElection() // Start election from this process
-
+// Function for a process to take over the data of the coordinator, unless it comes from an older one. Data from a
+// coordinator newer than the one the process follows means the process was cut off from its election, as by a
+// partition that has healed since, so it leaves its coordinator, or steps down as the coordinator, and asks only the
+// newer one, the one process of its ring it knows of, to let it in. The data of the coordinator it left is older from
+// then on. A process that is asking to join anyway asks the coordinator it hears from too
+func (p *Process) receiveData(msg Message) {
+	if p.olderCoordinator(msg.term, msg.from) {
+		fmt.Printf("\033[33mProcess %d ignores the data of Coordinator %d from term %d.\033[0m\n", p.id, msg.from, msg.term)
+		return
+	}
+	p.lock.Lock()
+	p.data = msg.data
+	fmt.Printf("Process %d updated its data to: %d (received from Coordinator %d)\n", p.id, p.data, msg.from)
+	shivizLog.Log(p.id-1, p.vectorClock.Merge(msg.vectorTimeStamp), fmt.Sprintf("Process %d updates its data to %d", p.id, p.data))
+	p.lock.Unlock()
+	if !p.joining && msg.from != p.coordinator && p.newerCoordinator(msg.term, msg.from) {
+		if p.id == p.coordinator {
+			fmt.Printf("\033[36mCoordinator %d of term %d hears from Coordinator %d of term %d and steps down.\033[0m\n", p.id, p.coordTerm, msg.from, msg.term)
+		} else {
+			fmt.Printf("\033[36mProcess %d hears from Coordinator %d of term %d, newer than Coordinator %d it follows.\033[0m\n", p.id, msg.from, msg.term, p.coordinator)
 		}
-		electionMutex.Unlock()
+		p.coordinator, p.coordTerm, p.term = msg.from, msg.term, max(p.term, msg.term)
+		p.ring = []int{p.id, msg.from}
+		p.watchSuccessor()
+		p.leave()
+	}
+	if p.joining {
+		p.send(msg.from, Message{kind: JOIN_MESSAGE, data: p.joins}, fmt.Sprintf("Process %d asks Coordinator %d to join its ring", p.id, msg.from))
+	}
+}
+
+// Function to start watching the heartbeats of another process afresh
+func (p *Process) monitor(id int) {
+	now := scheduler.Now()
+	p.detectors[id] = newDetector(now)
+	p.lastHeartbeat[id] = now
+}
+
+// Function to record a heartbeat of a process, if this process monitors it
+func (p *Process) heartbeat(id int) {
+	if d := p.detectors[id]; d != nil {
+		now := scheduler.Now()
+		d.Heartbeat(now)
+		p.lastHeartbeat[id] = now
+	}
+}
 
+// Function to check on the coordinator and initiate an election if its failure detector suspects it has crashed. Until
+// the process learns of a new coordinator it tries again on every heartbeat tick
+func (p *Process) checkCoordinatorStatus() {
+	now := scheduler.Now()
+	if p.suspected {
+		p.startElection(fmt.Sprintf("Process %d still knows of no Coordinator", p.id))
+		return
+	}
+	d := p.detectors[p.coordinator]
+	if !d.Suspect(now) {
+		return
+	}
+	p.suspected = true
+	_, crashed := p.coordinatorCrashedAt()
+	p.lock.Lock()
+	p.suspicions++
+	if !crashed {
+		p.falseSuspicions++
 	}
+	p.lock.Unlock()
+	shivizLog.Log(p.id-1, p.vectorClock.Tick(), fmt.Sprintf("Process %d suspects that Coordinator %d has crashed", p.id, p.coordinator))
+	p.startElection(fmt.Sprintf("Process %d suspects that Coordinator %d has crashed after %v without a heartbeat (suspicion level %.2f)", p.id, p.coordinator, now.Sub(p.lastHeartbeat[p.coordinator]), d.Level(now)))
 }
 
-// Function for a process to initiate an election
+// Function for a process to initiate an election of a new term for the given reason, unless it takes part in an
+// election already
+func (p *Process) startElection(reason string) {
+	if p.inElection() {
+		return
+	}
+	p.newTerm()
+	fmt.Printf("\033[32m%s, initiating election of term %d.\033[0m\n", reason, p.term)
+	p.initiateElection() // Start election from this process
+}
+
+// Function for a process to initiate an election with the algorithm chosen with -election
 func (p *Process) initiateElection() {
+	switch ELECTION {
+	case "bully":
+		p.initiateBully()
+		return
+	case "chang-roberts":
+		p.initiateChangRoberts()
+		return
+	case "hirschberg-sinclair":
+		p.initiateHirschbergSinclair()
+		return
+	}
 	electionRing := []int{p.id}
 	fmt.Printf("\033[32mProcess %d is starting the election, initial ring: %v\033[0m\n", p.id, electionRing)
-	p.sendRingToNextActiveProcess(electionRing)
+	shivizLog.Log(p.id-1, p.vectorClock.Tick(), fmt.Sprintf("Process %d starts an election", p.id))
+	p.pass(Message{kind: ELECTION_MESSAGE, ring: electionRing, term: p.term, initiator: p.id}, 1)
 }
 
-// Function to pass the ring to the next active process
-func (p *Process) sendRingToNextActiveProcess(ring []int) {
-	for i := 1; i < len(p.ring); i++ {
-		nextProcessID := p.ring[i]
-		nextProcess := findProcessByID(nextProcessID)
-		if nextProcess != nil && nextProcess.status == 1 {
-			fmt.Printf("\033[32mProcess %d passing ring %v to Process %d\033[0m\n", p.id, ring, nextProcess.id)
-			nextProcess.receiveRing(ring)
-			return
+// Function to pass an election, announcement or membership update to the process at position next of this process's
+// ring
+func (p *Process) pass(msg Message, next int) {
+	p.passAlong(msg, p.ring, next)
+}
+
+// Function to pass a message to the process at position next of ring. Until that process acknowledges the message it
+// may have crashed, so once ACK_TIMEOUT has passed without an answer the message goes to the one after it
+func (p *Process) passAlong(msg Message, ring []int, next int) {
+	if next >= len(ring) {
+		// No active process found, end the election
+		fmt.Printf("\033[32mProcess %d could not find any active process to pass the %s.\033[0m\n", p.id, kindName(msg))
+		switch {
+		case msg.kind == ELECTION_MESSAGE && (len(msg.ring) == 1 || msg.candidate == p.id) && p.current(msg):
+			p.receiveElection(msg) // The process is the only one left, so the election is over
+		case msg.kind == COORDINATOR_MESSAGE:
+			p.endElection(msg.term)
 		}
+		return
 	}
-	// No active process found, end the election
-	fmt.Printf("\033[32mProcess %d could not find any active process to pass the ring.\033[0m\n", p.id)
+	nextProcessID := ring[next]
+	p.attempt++
+	msg.attempt = p.attempt
+	p.handovers[p.attempt] = &handover{msg, ring, next}
+	switch {
+	case msg.kind == ELECTION_MESSAGE && msg.candidate != 0:
+		fmt.Printf("\033[32mProcess %d passing candidate %d to Process %d\033[0m\n", p.id, msg.candidate, nextProcessID)
+		p.send(nextProcessID, msg, fmt.Sprintf("Process %d passes candidate %d to Process %d", p.id, msg.candidate, nextProcessID))
+	case msg.kind == REPLY_MESSAGE:
+		fmt.Printf("\033[32mProcess %d passing the reply to candidate %d to Process %d\033[0m\n", p.id, msg.candidate, nextProcessID)
+		p.send(nextProcessID, msg, fmt.Sprintf("Process %d passes the reply to candidate %d to Process %d", p.id, msg.candidate, nextProcessID))
+	case msg.kind == ELECTION_MESSAGE:
+		fmt.Printf("\033[32mProcess %d passing ring %v to Process %d\033[0m\n", p.id, msg.ring, nextProcessID)
+		p.send(nextProcessID, msg, fmt.Sprintf("Process %d passes ring %v to Process %d", p.id, msg.ring, nextProcessID))
+	case msg.kind == COORDINATOR_MESSAGE:
+		fmt.Printf("\033[32mProcess %d passing the announcement of Coordinator %d to Process %d\033[0m\n", p.id, msg.coordinator, nextProcessID)
+		p.send(nextProcessID, msg, fmt.Sprintf("Process %d passes the announcement of Coordinator %d to Process %d", p.id, msg.coordinator, nextProcessID))
+	case msg.kind == MEMBERSHIP_MESSAGE:
+		fmt.Printf("\033[32mProcess %d passing ring structure %v of Coordinator %d to Process %d\033[0m\n", p.id, msg.ring, msg.coordinator, nextProcessID)
+		p.send(nextProcessID, msg, fmt.Sprintf("Process %d passes ring structure %v to Process %d", p.id, msg.ring, nextProcessID))
+	}
+	p.after(ACK_TIMEOUT, Message{kind: ACK_TIMEOUT_MESSAGE, attempt: p.attempt})
 }
 
 // Function to receive the ring and process it
-func (p *Process) receiveRing(ring []int) {
+func (p *Process) receiveRing(msg Message) {
+	ring := msg.ring
+	shivizLog.Log(p.id-1, p.vectorClock.Merge(msg.vectorTimeStamp), fmt.Sprintf("Process %d receives ring %v", p.id, ring))
 	// Check if the current process's ID is already in the ring
-	for _, id := range ring {
-		if id == p.id {
-			// Election complete, update the new ring structure
-			fmt.Printf("\033[32mProcess %d found its ID in the ring. Updating new ring structure: %v\033[0m\n", p.id, ring)
-			p.updateRing(ring)
-			return
-		}
+	if slices.Contains(ring, p.id) {
+		// Election complete, announce the new coordinator around the ring
+		fmt.Printf("\033[32mProcess %d found its ID in the ring. New ring structure: %v\033[0m\n", p.id, ring)
+		p.announceCoordinator(ring, slices.Max(ring))
+		return
 	}
 
 	// Add the current process's ID to the ring
 	newRing := append(ring, p.id)
 	fmt.Printf("\033[32mProcess %d adding itself to the ring, new ring: %v\033[0m\n", p.id, newRing)
-	p.sendRingToNextActiveProcess(newRing)
+	p.pass(Message{kind: ELECTION_MESSAGE, ring: newRing, term: msg.term, initiator: msg.initiator}, 1)
+}
+
+// Function to receive an election message that is passed round the ring, with the algorithm chosen with -election
+func (p *Process) receiveElection(msg Message) {
+	switch ELECTION {
+	case "chang-roberts":
+		p.receiveCandidate(msg)
+	case "hirschberg-sinclair":
+		p.receiveProbe(msg)
+	default:
+		p.receiveRing(msg)
+	}
 }
 
-// Function to update the ring structure for all active processes
-func (p *Process) updateRing(newRing []int) {
-	for i, id := range newRing {
-		process := findProcessByID(id)
-		if process != nil && process.status == 1 {
-			modifiedRing := append(newRing[i:], newRing[:i]...)
-			process.lock.Lock()
-			process.ring = modifiedRing
-			process.lock.Unlock()
-			fmt.Printf("\033[32mProcess %d updated with new ring structure: %v\033[0m\n", process.id, modifiedRing)
+// Function for the process that decided an election to announce the new coordinator and ring structure round the ring
+func (p *Process) announceCoordinator(ring []int, newCoordinator int) {
+	fmt.Printf("\033[34mProcess %d is elected as the new Coordinator.\033[0m\n", newCoordinator)
+	shivizLog.Log(p.id-1, p.vectorClock.Tick(), fmt.Sprintf("Process %d elects Process %d as Coordinator", p.id, newCoordinator))
+	p.announcements++
+	announcement := Message{kind: COORDINATOR_MESSAGE, ring: ring, coordinator: newCoordinator, announcer: p.id, announcement: p.announcements, term: p.term}
+	p.learnCoordinator(announcement)
+	p.pass(announcement, 1)
+}
+
+// Function to receive the announcement of a new coordinator or a membership update, which goes round the ring until it
+// is back at a process that has already received it
+func (p *Process) receiveCoordinator(msg Message) {
+	shivizLog.Log(p.id-1, p.vectorClock.Merge(msg.vectorTimeStamp), fmt.Sprintf("Process %d receives the %s of Coordinator %d", p.id, kindName(msg), msg.coordinator))
+	if latest := p.announced[msg.announcer]; msg.announcement == latest || msg.announcer == p.id {
+		// Back at its announcer, which may have sent out a newer membership update since
+		fmt.Printf("\033[32mThe %s of Coordinator %d has gone round the ring back to Process %d.\033[0m\n", kindName(msg), msg.coordinator, p.id)
+		if msg.kind == COORDINATOR_MESSAGE {
+			p.endElection(msg.term)
+		}
+		return
+	} else if msg.announcement < latest {
+		fmt.Printf("\033[32mProcess %d drops an outdated %s of Coordinator %d.\033[0m\n", p.id, kindName(msg), msg.coordinator)
+		return
+	}
+	if p.olderCoordinator(msg.term, msg.coordinator) {
+		fmt.Printf("\033[32mProcess %d drops the %s of Coordinator %d from term %d, it is in term %d.\033[0m\n", p.id, kindName(msg), msg.coordinator, msg.term, p.term)
+		return
+	}
+	if !slices.Contains(msg.ring, p.id) {
+		// The election skipped the process, which may only have been slow, so it asks to be let into the new ring
+		p.pass(msg, 1)
+		if !p.joining {
+			fmt.Printf("\033[36mProcess %d is not in the ring of Coordinator %d.\033[0m\n", p.id, msg.coordinator)
+			p.startJoining()
 		}
+		return
+	}
+	joining := p.joining
+	p.learnCoordinator(msg)
+	p.pass(msg, 1)
+	if joining {
+		p.sendAlive() // The process before it may still be watching it from before it crashed
+	}
+	if joining && p.id > p.coordinator {
+		// A recovered process with a higher ID than the coordinator takes over, so that the highest active process is the
+		// coordinator again
+		p.startElection(fmt.Sprintf("Process %d has a higher ID than Coordinator %d", p.id, p.coordinator))
 	}
+}
 
-	// Elect the process with the highest ID as the coordinator
-	maxID := newRing[0]
-	for _, id := range newRing {
-		if id > maxID {
-			maxID = id
+// Function for a process to take over the ring structure and coordinator of an election or membership update, with the
+// ring starting at itself
+func (p *Process) learnCoordinator(announcement Message) {
+	ring, newCoordinator := announcement.ring, announcement.coordinator
+	i := slices.Index(ring, p.id)
+	p.ring = append(append([]int{}, ring[i:]...), ring[:i]...)
+	defer p.watchSuccessor()
+	p.announced[announcement.announcer] = announcement.announcement
+	for attempt, h := range p.handovers {
+		if h.msg.kind == ELECTION_MESSAGE || h.msg.kind == REPLY_MESSAGE {
+			delete(p.handovers, attempt) // The election is over
 		}
 	}
-	newCoordinator := findProcessByID(maxID)
-	if newCoordinator != nil {
-		newCoordinator.elected = true
-		coordinator = newCoordinator
-		fmt.Printf("\033[34mProcess %d is elected as the new Coordinator.\033[0m\n", newCoordinator.id)
+	fmt.Printf("\033[32mProcess %d updated with new ring structure: %v\033[0m\n", p.id, p.ring)
+	shivizLog.Log(p.id-1, p.vectorClock.Tick(), fmt.Sprintf("Process %d updates its ring to %v", p.id, p.ring))
+	if announcement.kind == MEMBERSHIP_MESSAGE && newCoordinator == p.coordinator && !p.joining {
+		return
 	}
-	electionInProgress = false
+	p.takeCoordinator(newCoordinator, announcement.term)
+}
+
+// Function for a process to take a new coordinator elected in term. It logs how long the process was without a known
+// coordinator, from the crash of the one it knew of or from its own recovery
+func (p *Process) takeCoordinator(newCoordinator, term int) {
+	var without time.Duration
+	if p.joining {
+		without = scheduler.Now().Sub(p.joiningSince)
+		p.joining = false
+	} else if crashedAt, crashed := p.coordinatorCrashedAt(); crashed {
+		without = scheduler.Now().Sub(crashedAt)
+		p.lock.Lock()
+		p.failovers++
+		p.failoverTime += without
+		p.longestFailover = max(p.longestFailover, without)
+		p.lock.Unlock()
+	}
+	p.coordinator, p.coordTerm, p.suspected = newCoordinator, term, false
+	p.term, p.initiator = term, 0
+	p.monitor(newCoordinator)
+	fmt.Printf("\033[34mProcess %d learned that Process %d is the Coordinator after %v without a known Coordinator.\033[0m\n", p.id, newCoordinator, without)
+	if newCoordinator == p.id {
+		p.elected = true
+		electionMutex.Lock()
+		coordinator = p
+		electionMutex.Unlock()
+	}
+	p.shareView()
+	crashWhileAnnounced(newCoordinator, term)
+}
+
+// Function to tell whether the coordinator this process knows of has crashed since its last heartbeat, even if it has
+// recovered since, and when. The process itself cannot know this, only the logs and reports of the run use it
+func (p *Process) coordinatorCrashedAt() (time.Time, bool) {
+	return p.crashedSinceHeartbeat(p.coordinator)
+}
+
+// Function to tell whether a process this one monitors has crashed since its last heartbeat, and when
+func (p *Process) crashedSinceHeartbeat(id int) (time.Time, bool) {
+	known := findProcessByID(id)
+	known.lock.Lock()
+	defer known.lock.Unlock()
+	return known.crashedAt, !known.crashedAt.IsZero() && !known.crashedAt.Before(p.lastHeartbeat[id])
+}
+
+// Function to count the election of term as over once the new coordinator has been announced. The messages since the
+// last election ended count towards it, with those of the elections it extinguished
+func (p *Process) endElection(term int) {
+	statsMutex.Lock()
+	if term <= lastTermEnded {
+		statsMutex.Unlock()
+		return
+	}
+	lastTermEnded = term
+	messages, bytes := electionSent, electionBytes
+	electionSent, electionBytes = 0, 0
+	electionsHeld++
+	statsMutex.Unlock()
+	fmt.Printf("\033[34mThe election of term %d took %d messages and %d bytes.\033[0m\n", term, messages, bytes)
 }
 
 // Method to update the data for a process
@@ -149,6 +530,7 @@ func (p *Process) updateData(newData int) {
 	defer p.lock.Unlock()
 	p.data = newData
 	fmt.Printf("\033[33mProcess %d changed its data to: %d\033[0m\n", p.id, newData)
+	shivizLog.Log(p.id-1, p.vectorClock.Tick(), fmt.Sprintf("Process %d changes its data to %d", p.id, newData))
 }
 
 // Function to find a process by its ID
@@ -161,11 +543,25 @@ func findProcessByID(id int) *Process {
 	return nil
 }
 
+// Function to tell whether a process is active, as processes crash and recover while others look at them
+func (p *Process) active() bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.status == 1
+}
+
+// Function to get the coordinator as the crash schedule and the data changes see it
+func currentCoordinator() *Process {
+	electionMutex.Lock()
+	defer electionMutex.Unlock()
+	return coordinator
+}
+
 // Function to get the list of active processes
 func getActiveProcesses() []*Process {
 	active := []*Process{}
 	for _, proc := range processes {
-		if proc.status == 1 {
+		if proc.active() {
 			active = append(active, proc)
 		}
 	}
@@ -175,45 +571,154 @@ func getActiveProcesses() []*Process {
 // Function to crash a process
 func crashProcess(id int) {
 	for _, proc := range processes {
-		if proc.id == id && proc.status == 1 {
+		if proc.id == id && proc.active() {
+			electionMutex.Lock()
+			if proc == coordinator {
+				coordinatorCrashes++
+			}
+			electionMutex.Unlock()
 			proc.lock.Lock()
 			proc.status = 0
+			proc.crashedAt = scheduler.Now()
 			proc.lock.Unlock()
+			checkSplitBrain()
+			shivizLog.Log(proc.id-1, proc.vectorClock.Tick(), fmt.Sprintf("Process %d crashes", id))
 			fmt.Printf("\033[31mProcess %d crashed (This process leaves silently, its not annoucement. Just for us to know when a process has crashed.).\033[0m\n", id)
 			break
 		}
 	}
 }
 
-// Function to randomly change data for non-coordinator active processes
+// Function to randomly change data for non-coordinator active processes every 5 to 14 seconds
 func randomlyChangeData() {
-	for {
-		time.Sleep(time.Duration(rand.Intn(10)+5) * time.Second)
+	scheduler.AfterFunc(time.Duration(random.Intn("data interval", 10)+5)*time.Second, func() {
+		if runOver() {
+			return
+		}
+		defer randomlyChangeData()
 		activeProcesses := getActiveProcesses()
 
 		// Exclude the coordinator from the selection
 		if len(activeProcesses) <= 1 {
-			continue
+			return
 		}
 
 		var targetProcess *Process
+		coordinator := currentCoordinator()
 		for {
-			targetProcess = activeProcesses[rand.Intn(len(activeProcesses))]
+			targetProcess = activeProcesses[random.Intn("data process", len(activeProcesses))]
 			if targetProcess != coordinator {
 				break
 			}
 		}
 
 		// Update the data of the selected process
-		newData := rand.Intn(100)
+		newData := random.Intn("data", 100)
 		fmt.Printf("\033[33mChanging data for Process %d to: %d\033[0m\n", targetProcess.id, newData)
 		targetProcess.updateData(newData)
+	})
+}
+
+// Function to crash and recover the processes of the crash schedule, ending the run once all of them have crashed
+// unless the schedule recovers processes
+func crashProcesses(schedule []faults.Crash) {
+	faults.Run(schedule, scheduler, func(c faults.Crash) {
+		active := []int{}
+		for _, proc := range getActiveProcesses() {
+			active = append(active, proc.id)
+		}
+		id := c.Choose(len(processes), active, currentCoordinator().id, random.For("crash").Intn)
+		if c.Recover {
+			recoverProcess(id)
+		} else {
+			crashProcess(id)
+		}
+		if runOver() {
+			endRun("\033[31mAll processes have ended. Terminating program.\033[0m")
+		}
+	}, runOver)
+}
+
+// Function to end the run, which only happens once
+func endRun(message string) {
+	endOnce.Do(func() {
+		fmt.Println(message)
+		if engine, ok := scheduler.(*sim.Engine); ok {
+			engine.Stop()
+		}
+		close(finished)
+	})
+}
+
+// Function to report how often each process suspected its coordinator, and how often it was wrong
+func reportSuspicions() {
+	total, falsely, spliced, wrongly := 0, 0, 0, 0
+	for _, proc := range processes {
+		proc.lock.Lock()
+		fmt.Printf("Process %d suspected its Coordinator %d times, %d of them falsely.\n", proc.id, proc.suspicions, proc.falseSuspicions)
+		total += proc.suspicions
+		falsely += proc.falseSuspicions
+		spliced += proc.splices
+		wrongly += proc.falseSplices
+		proc.lock.Unlock()
+	}
+	detectedBy := "detector " + DETECTOR
+	if ELECTION == "raft" {
+		detectedBy = fmt.Sprintf("election timeout %v to %v", RAFT_TIMEOUT, 2*RAFT_TIMEOUT)
+	}
+	fmt.Printf("%d suspicions in total, %d of them false (%s, heartbeat every %v).\n", total, falsely, detectedBy, HEARTBEAT_INTERVAL)
+	if spliced > 0 {
+		fmt.Printf("%d splices out of the ring in total, %d of them false.\n", spliced, wrongly)
 	}
 }
 
+// Function to report how many messages the elections took and how long the processes were without a coordinator
+// after it crashed, to compare the election algorithms
+func reportElections() {
+	failovers := 0
+	var failoverTime, longest time.Duration
+	for _, proc := range processes {
+		proc.lock.Lock()
+		failovers += proc.failovers
+		failoverTime += proc.failoverTime
+		longest = max(longest, proc.longestFailover)
+		proc.lock.Unlock()
+	}
+	statsMutex.Lock()
+	defer statsMutex.Unlock()
+	messages, bytes := 0, 0
+	counts := []string{}
+	for _, kind := range []int{ELECTION_MESSAGE, OK_MESSAGE, REPLY_MESSAGE, COORDINATOR_MESSAGE, ACK_MESSAGE, REQUEST_VOTE_MESSAGE, VOTE_MESSAGE} {
+		if sentByKind[kind] > 0 {
+			messages += sentByKind[kind]
+			bytes += bytesByKind[kind]
+			counts = append(counts, fmt.Sprintf("%s %d (%d bytes)", messageNames[kind], sentByKind[kind], bytesByKind[kind]))
+		}
+	}
+	fmt.Printf("%d elections were started and %d of them ended with a Coordinator, the others were extinguished or lost with a crashed process.\n", electionsStarted, electionsHeld)
+	fmt.Printf("%d elections (%s) took %d messages and %d bytes: %s.\n", electionsHeld, ELECTION, messages, bytes, strings.Join(counts, ", "))
+	fmt.Printf("That is %s messages and %s bytes per election, %s messages and %s bytes per crash of a Coordinator.\n", perEvent(messages, electionsHeld), perEvent(bytes, electionsHeld), perEvent(messages, coordinatorCrashes), perEvent(bytes, coordinatorCrashes))
+	if failovers > 0 {
+		fmt.Printf("After a Coordinator crashed the processes were without one for %v on average and %v at most.\n", (failoverTime / time.Duration(failovers)).Round(time.Millisecond), longest.Round(time.Millisecond))
+	}
+}
+
+// Function to format a count per event for the report, or - if there was none
+func perEvent(count, events int) string {
+	if events == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.1f", float64(count)/float64(events))
+}
+
+// Function to tell whether the run is over because every process has crashed for good
+func runOver() bool {
+	return !recovering && allProcessesCrashed()
+}
+
 func allProcessesCrashed() bool {
 	for _, proc := range processes {
-		if proc.status == 1 {
+		if proc.active() {
 			return false
 		}
 	}
@@ -221,13 +726,94 @@ func allProcessesCrashed() bool {
 }
 
 func main() {
-	rand.Seed(time.Now().UnixNano())
+	flag.StringVar(&SHIVIZ_FILE, "shiviz", "", "write a log for the ShiViz visualiser to this file")
+	flag.BoolVar(&SIMULATE, "sim", false, "run the processes in virtual time, taking every random decision from the seed")
+	flag.Int64Var(&SEED, "seed", 0, "seed of every random decision, taken from the clock when 0")
+	record := flag.String("record", "", "record every random decision to this JSON Lines file")
+	replay := flag.String("replay", "", "repeat the random decisions recorded in this file, as long as the run asks for the same ones")
 	var numProcesses int
-	fmt.Print("\033[38;5;88mEnter the number of processes: \033[0m")
-	fmt.Scanln(&numProcesses)
+	flag.IntVar(&numProcesses, "processes", 0, "number of processes, asked for when not given")
+	flag.DurationVar(&HEARTBEAT_INTERVAL, "heartbeat", HEARTBEAT_INTERVAL, "time between two rounds of the coordinator sending its data and the others checking on it")
+	flag.DurationVar(&ACK_TIMEOUT, "ack-timeout", ACK_TIMEOUT, "time a process waits for the next one in the ring to acknowledge an election message before passing it further on, or for an answer to a Bully election")
+	flag.StringVar(&DETECTOR, "detector", DETECTOR, detector.Usage)
+	flag.StringVar(&ELECTION, "election", ELECTION, "election algorithm: "+strings.Join(ELECTIONS, ", "))
+	flag.DurationVar(&RAFT_TIMEOUT, "raft-timeout", RAFT_TIMEOUT, "shortest election timeout of -election raft, each timeout is drawn between it and twice it")
+	flag.StringVar(&SCENARIO, "scenario", "", "crash scenario of Q2_3A or Q2_3B to run on top of the crash schedule: "+strings.Join(SCENARIOS, ", "))
+	flag.StringVar(&NET, "net", "", netsim.Usage)
+	flag.StringVar(&CRASHES, "crashes", CRASHES, faults.RecoveryUsage)
+	flag.StringVar(&PARTITIONS, "partitions", "", faults.PartitionUsage)
+	flag.DurationVar(&DURATION, "duration", 0, "end the run after this long even if some processes are still active, 0 for no limit; a simulation whose crash schedule leaves processes running needs one")
+	configFile := flag.String("config", "", config.Usage)
+	flag.Parse()
+	if err := config.Load(flag.CommandLine, *configFile); err != nil {
+		fmt.Println(err)
+		os.Exit(2)
+	}
+	if !slices.Contains(ELECTIONS, ELECTION) {
+		fmt.Printf("Unknown election algorithm %q, it must be one of %s.\n", ELECTION, strings.Join(ELECTIONS, ", "))
+		os.Exit(2)
+	}
+	if SCENARIO != "" && !slices.Contains(SCENARIOS, SCENARIO) {
+		fmt.Printf("Unknown crash scenario %q, it must be one of %s.\n", SCENARIO, strings.Join(SCENARIOS, ", "))
+		os.Exit(2)
+	}
+	crashes, err := faults.ParseWithRecoveries(CRASHES)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(2)
+	}
+	var partitions []faults.Partition
+	if PARTITIONS != "" {
+		if partitions, err = faults.ParsePartitions(PARTITIONS); err != nil {
+			fmt.Println(err)
+			os.Exit(2)
+		}
+	}
+	if recovering = faults.Recovers(crashes); recovering && DURATION == 0 {
+		fmt.Println("A crash schedule that recovers processes never ends by itself, it needs -duration.")
+		os.Exit(2)
+	}
+	if newDetector, err = detector.Parse(DETECTOR, HEARTBEAT_INTERVAL); err != nil {
+		fmt.Println(err)
+		os.Exit(2)
+	}
+	// Every random decision comes from one source, so that a run can be repeated from its seed or its recording
+	random, err = chance.Open(SEED, *record, *replay)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(2)
+	}
+	SEED = random.Seed()
+	defer random.Close()
+	var engine *sim.Engine
+	if SIMULATE {
+		engine = sim.NewEngine()
+		scheduler = engine
+	}
+	if network, err = netsim.Parse(NET, scheduler, random.For("net")); err != nil {
+		fmt.Println(err)
+		os.Exit(2)
+	}
+	if numProcesses == 0 {
+		fmt.Print("\033[38;5;88mEnter the number of processes: \033[0m")
+		fmt.Scanln(&numProcesses)
+	}
+	if numProcesses < 1 {
+		fmt.Println("You need at least 1 process.")
+		os.Exit(2)
+	}
+	if SIMULATE && DURATION <= 0 && !faults.CrashesAll(crashes, numProcesses) {
+		// The processes left running would keep the simulation busy forever
+		fmt.Println("A simulation whose crash schedule leaves processes running needs a positive -duration.")
+		os.Exit(2)
+	}
+	if highest := faults.MaxProcess(partitions); highest > numProcesses {
+		fmt.Printf("The partitions name Process %d, but there are only %d processes.\n", highest, numProcesses)
+		os.Exit(2)
+	}
 	// Initialize processes and create the ring structure
 	for i := 1; i <= numProcesses; i++ {
-		processes = append(processes, &Process{id: i, status: 1, data: rand.Intn(100)})
+		processes = append(processes, &Process{id: i, status: 1, data: random.Intn("data", 100), inbox: newMailbox(), handovers: make(map[int]*handover), admitted: make(map[int]int), joinedIn: make(map[int]int), electors: make(map[int]bool), voters: make(map[int]bool), announced: make(map[int]int), detectors: make(map[int]detector.Detector), lastHeartbeat: make(map[int]time.Time), vectorClock: clock.NewVectorClock(i-1, numProcesses)})
 	}
 
 	// Link the process IDs in the ring
@@ -238,6 +824,18 @@ func main() {
 		}
 	}
 
+	if SHIVIZ_FILE != "" {
+		hosts := make([]string, numProcesses)
+		for i := range hosts {
+			hosts[i] = fmt.Sprintf("Process%d", i+1)
+		}
+		shivizLog, err = shiviz.Create(SHIVIZ_FILE, hosts)
+		if err != nil {
+			fmt.Printf("Could not create ShiViz log: %v\n", err)
+		}
+		shivizLog.CloseOnInterrupt()
+	}
+
 	// Set the initial coordinator to the process with the highest ID
 	coordinator = processes[0]
 	for _, proc := range processes {
@@ -246,24 +844,43 @@ func main() {
 		}
 	}
 	coordinator.elected = true // Mark as the coordinator
+	for _, proc := range processes {
+		proc.coordinator = coordinator.id
+		proc.shareView()
+		proc.monitor(coordinator.id)
+		if ELECTION == "raft" {
+			proc.resetElectionTimer()
+		} else {
+			proc.watchSuccessor()
+		}
+	}
 	fmt.Printf("\033[34mProcess %d is the initial Coordinator with the following ring structure %v.\033[0m\n", coordinator.id, coordinator.ring)
 	for _, proc := range processes {
-		wg.Add(1)
-		go proc.run()
+		if !SIMULATE {
+			go proc.listen()
+		}
+		proc.run()
 	}
-	go randomlyChangeData()
+	randomlyChangeData()
 
-	// Randomly crash and activate processes
-	go func() {
-		for {
-			if allProcessesCrashed() {
-				fmt.Println("\033[31mAll processes have ended. Terminating program.\033[0m")
-				os.Exit(0)
-			}
-			time.Sleep(10 * time.Second)
-			crashProcess(rand.Intn(numProcesses) + 1) // Crash a random process
-			time.Sleep(10 * time.Second)
-		}
-	}()
-	wg.Wait()
+	crashProcesses(crashes)
+	partitionNetwork(partitions)
+	if DURATION > 0 {
+		scheduler.AfterFunc(DURATION, func() {
+			endRun(fmt.Sprintf("\033[31mThe run is over after %v. Terminating program.\033[0m", DURATION))
+		})
+	}
+	if SIMULATE {
+		// The simulation stops at the end of the run, or runs out of events once every process has crashed
+		start := time.Now()
+		events := engine.Run(0)
+		fmt.Printf("Simulated %v in %d events (seed %d) in %v.\n", engine.Elapsed(), events, SEED, time.Since(start).Round(time.Microsecond))
+	}
+	<-finished
+	reportSuspicions()
+	reportElections()
+	reportSplitBrain()
+	if err := shivizLog.Close(); err != nil {
+		fmt.Printf("Could not write ShiViz log: %v\n", err)
+	}
 }
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Jashveragiwala/Lamport-and-Vector-Clocks/sim"
+)
+
+// Runs the program as a simulation with the given flags, starting from a fresh state
+func simulateRun(t *testing.T, args ...string) {
+	t.Helper()
+	args0 := os.Args
+	t.Cleanup(func() { os.Args = args0 })
+	processes, coordinator = nil, nil
+	finished, endOnce = make(chan bool), sync.Once{}
+	coordinatorCrashes, electionsStarted, electionsHeld, lastTermEnded = 0, 0, 0, 0
+	sentByKind, bytesByKind = make(map[int]int), make(map[int]int)
+	electionSent, electionBytes = 0, 0
+	announcedCrashed, announcedTerm, learned, crashStep, electionCrashTerm = false, 0, 0, 0, 0
+	partitioned, splitSince, splitCoordinators, splitCounted = false, time.Time{}, nil, false
+	overlaps, splitBrains, splitTime, longestSplit, mostValues = 0, 0, 0, 0, 0
+	scheduler = sim.RealTime()
+	flag.CommandLine = flag.NewFlagSet("Q2_1", flag.ContinueOnError)
+	os.Args = append([]string{"Q2_1", "-sim"}, args...)
+	main()
+	// The flags write straight into the settings, which the next run must find at their defaults again
+	flag.CommandLine.VisitAll(func(f *flag.Flag) { f.Value.Set(f.DefValue) })
+}
+
+// Every process left has to agree on the highest active process as the coordinator after each crash of one
+func TestElectionAgreesOnTheHighestActiveProcess(t *testing.T) {
+	for seed := 1; seed <= 10; seed++ {
+		simulateRun(t, "-processes", "5", "-seed", strconv.Itoa(seed), "-crashes", "coordinator@10s/40s", "-duration", "75s")
+
+		var active []int
+		for _, proc := range getActiveProcesses() {
+			active = append(active, proc.id)
+		}
+		if !slices.Equal(active, []int{1, 2, 3}) {
+			t.Fatalf("seed %d: active processes are %v, want the coordinators 5 and 4 to have crashed", seed, active)
+		}
+		if coordinator.id != 3 {
+			t.Errorf("seed %d: Process %d is the coordinator, want 3", seed, coordinator.id)
+		}
+		for _, proc := range getActiveProcesses() {
+			ring := slices.Clone(proc.ring)
+			slices.Sort(ring)
+			if proc.ring[0] != proc.id || !slices.Equal(ring, active) || len(proc.handovers) != 0 {
+				t.Errorf("seed %d: Process %d ends with ring %v, want one of the active processes %v starting with itself", seed, proc.id, proc.ring, active)
+			}
+		}
+	}
+}
+
+// Sums up the suspicions of every process and how many of them were false
+func countSuspicions() (suspicions, falseSuspicions int) {
+	for _, proc := range processes {
+		suspicions += proc.suspicions
+		falseSuspicions += proc.falseSuspicions
+	}
+	return suspicions, falseSuspicions
+}
+
+// A detector that times out before the next heartbeat is due suspects a coordinator that never crashed, and every such suspicion counts as false
+func TestDetectorCountsFalseSuspicions(t *testing.T) {
+	simulateRun(t, "-processes", "4", "-seed", "1", "-heartbeat", "4s", "-detector", "3s", "-crashes", "1@1h", "-duration", "30s")
+	suspicions, falseSuspicions := countSuspicions()
+	if suspicions == 0 || falseSuspicions != suspicions {
+		t.Errorf("%d suspicions, %d of them false, want every one of some false", suspicions, falseSuspicions)
+	}
+}
+
+// A detector that waits for more than two heartbeats only suspects coordinators that crashed
+func TestDetectorSuspectsOnlyCrashedCoordinators(t *testing.T) {
+	for seed := 1; seed <= 5; seed++ {
+		simulateRun(t, "-processes", "5", "-seed", strconv.Itoa(seed), "-heartbeat", "4s", "-detector", "timeout:10s", "-crashes", "coordinator@10s/40s", "-duration", "75s")
+		suspicions, falseSuspicions := countSuspicions()
+		if suspicions == 0 || falseSuspicions != 0 {
+			t.Errorf("seed %d: %d suspicions, %d of them false, want only true ones after the crashes", seed, suspicions, falseSuspicions)
+		}
+	}
+}
+
+// A recovered process with a higher ID takes over from the coordinator, and the two overlap until the announcement
+// has gone round the ring. Without a partition that is a handover, not split brain
+func TestHandoverIsNotSplitBrain(t *testing.T) {
+	for _, election := range []string{"ring", "bully", "chang-roberts", "hirschberg-sinclair"} {
+		for seed := 1; seed <= 3; seed++ {
+			simulateRun(t, "-processes", "6", "-seed", strconv.Itoa(seed), "-election", election, "-crashes", "6@10s,recover:6@40s", "-duration", "2m", "-net", "latency=uniform:0ms:300ms")
+			if overlaps == 0 {
+				t.Errorf("%s, seed %d: the coordinators never overlapped, want a handover to Process 6 after it recovered", election, seed)
+			}
+			if splitBrains != 0 {
+				t.Errorf("%s, seed %d: split brain happened %d times, want the handover not to count", election, seed, splitBrains)
+			}
+		}
+	}
+}
+
+// Each group of a partitioned network elects a coordinator of its own, which is split brain from the start
+func TestPartitionIsSplitBrain(t *testing.T) {
+	for _, election := range []string{"ring", "raft"} {
+		simulateRun(t, "-processes", "6", "-seed", "3", "-election", election, "-partitions", "1-4|5+6@20s,heal@60s", "-crashes", "1@10h", "-duration", "3m", "-net", "latency=uniform:0ms:300ms")
+		if splitBrains != 1 || longestSplit < 20*time.Second {
+			t.Errorf("%s: split brain happened %d times and lasted %v at most, want once for most of the partition", election, splitBrains, longestSplit)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Function for a process to start an election under the Bully algorithm. It challenges every process with a higher
+// ID, and if none of them answers within ACK_TIMEOUT it is the new coordinator
+func (p *Process) initiateBully() {
+	p.bullyRound++
+	p.electing, p.answered = true, false
+	higher := []int{}
+	for _, proc := range processes {
+		if proc.id > p.id {
+			higher = append(higher, proc.id)
+		}
+	}
+	if len(higher) == 0 {
+		p.becomeBullyCoordinator()
+		return
+	}
+	fmt.Printf("\033[32mProcess %d is starting a Bully election, challenging Processes %v\033[0m\n", p.id, higher)
+	shivizLog.Log(p.id-1, p.vectorClock.Tick(), fmt.Sprintf("Process %d starts an election", p.id))
+	for _, id := range higher {
+		p.send(id, Message{kind: ELECTION_MESSAGE, attempt: p.bullyRound, term: p.term}, fmt.Sprintf("Process %d challenges Process %d", p.id, id))
+	}
+	p.after(ACK_TIMEOUT, Message{kind: OK_TIMEOUT_MESSAGE, attempt: p.bullyRound})
+}
+
+// Function for a process to answer the Bully election of a process with a lower ID and take the election over in its
+// term. The answer tells the challenger of a newer term, and the process only takes over elections of its own term or
+// a newer one
+func (p *Process) receiveBullyElection(msg Message) {
+	shivizLog.Log(p.id-1, p.vectorClock.Merge(msg.vectorTimeStamp), fmt.Sprintf("Process %d receives the challenge of Process %d", p.id, msg.from))
+	if msg.term > p.term {
+		p.takePart(msg.term, msg.from)
+		p.electing = false
+	}
+	p.electors[msg.from] = true
+	fmt.Printf("\033[32mProcess %d answers the election of Process %d in term %d.\033[0m\n", p.id, msg.from, msg.term)
+	p.send(msg.from, Message{kind: OK_MESSAGE, attempt: msg.attempt, term: p.term}, fmt.Sprintf("Process %d answers Process %d", p.id, msg.from))
+	if !p.electing && msg.term == p.term {
+		if p.initiator == 0 {
+			p.takePart(p.term, msg.from)
+		}
+		p.initiateBully()
+	}
+}
+
+// Function for a process to take note that a process with a higher ID has taken its election over
+func (p *Process) receiveOK(msg Message) {
+	shivizLog.Log(p.id-1, p.vectorClock.Merge(msg.vectorTimeStamp), fmt.Sprintf("Process %d receives the answer of Process %d", p.id, msg.from))
+	if msg.term > p.term {
+		p.takePart(msg.term, msg.from) // Its coordinator comes from a newer election
+	}
+	if !p.electing || msg.attempt != p.bullyRound || p.answered {
+		return
+	}
+	p.answered = true
+	fmt.Printf("\033[32mProcess %d got an answer from Process %d, waiting for the new Coordinator.\033[0m\n", p.id, msg.from)
+}
+
+// Function for a process to go on with its Bully election once its timer has fired. Without an answer it is the new
+// coordinator. With one it waits for the coordinator to announce itself, and starts again if it never does
+func (p *Process) bullyTimeout(msg Message) {
+	if !p.electing || msg.attempt != p.bullyRound {
+		return // The election is over, or the timer belongs to an older one
+	}
+	switch {
+	case msg.kind == COORDINATOR_TIMEOUT_MESSAGE:
+		fmt.Printf("\033[32mProcess %d heard of no new Coordinator, starting the election again.\033[0m\n", p.id)
+		p.initiateBully()
+	case p.answered:
+		// Time for the process that answered to run its own election and announce itself
+		p.after(3*ACK_TIMEOUT, Message{kind: COORDINATOR_TIMEOUT_MESSAGE, attempt: p.bullyRound})
+	default:
+		p.becomeBullyCoordinator()
+	}
+}
+
+// Function for the process with the highest ID that answers to announce itself as the coordinator to every process
+// with a lower ID. Its ring holds the processes of its own ring and those whose elections it answered, and the ring
+// repair takes out any of them that have crashed
+func (p *Process) becomeBullyCoordinator() {
+	members := []int{p.id}
+	for _, id := range p.ring {
+		if id < p.id {
+			members = append(members, id)
+		}
+	}
+	for id := range p.electors {
+		if !slices.Contains(members, id) {
+			members = append(members, id)
+		}
+	}
+	ring := p.ringOf(members)
+	fmt.Printf("\033[34mProcess %d is elected as the new Coordinator.\033[0m\n", p.id)
+	shivizLog.Log(p.id-1, p.vectorClock.Tick(), fmt.Sprintf("Process %d elects itself as Coordinator", p.id))
+	p.announcements++
+	announcement := Message{kind: COORDINATOR_MESSAGE, ring: ring, coordinator: p.id, announcer: p.id, announcement: p.announcements, term: p.term}
+	p.learnBullyCoordinator(announcement)
+	for _, id := range ring[1:] {
+		p.send(id, announcement, fmt.Sprintf("Coordinator %d announces itself to Process %d", p.id, id))
+	}
+	p.endElection(p.term)
+}
+
+// Function for a process to learn the coordinator that announced itself under the Bully algorithm. A process with a
+// higher ID bullies it by starting an election of its own
+func (p *Process) receiveBullyCoordinator(msg Message) {
+	shivizLog.Log(p.id-1, p.vectorClock.Merge(msg.vectorTimeStamp), fmt.Sprintf("Process %d receives the announcement of Coordinator %d", p.id, msg.coordinator))
+	if msg.announcement <= p.announced[msg.announcer] || !slices.Contains(msg.ring, p.id) {
+		return
+	}
+	if p.olderCoordinator(msg.term, msg.coordinator) {
+		fmt.Printf("\033[32mProcess %d drops the announcement of Coordinator %d from term %d, it is in term %d.\033[0m\n", p.id, msg.coordinator, msg.term, p.term)
+		return
+	}
+	p.learnBullyCoordinator(msg)
+	if p.id > p.coordinator {
+		p.startElection(fmt.Sprintf("Process %d has a higher ID than Coordinator %d", p.id, p.coordinator))
+	}
+}
+
+// Function to end a process's part in a Bully election once it knows the coordinator
+func (p *Process) learnBullyCoordinator(announcement Message) {
+	p.electing = false
+	clear(p.electors)
+	p.learnCoordinator(announcement)
+}
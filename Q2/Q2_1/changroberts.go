@@ -0,0 +1,33 @@
+package main
+
+import "fmt"
+
+// Function for a process to start a Chang-Roberts election. Only its ID goes round the ring, and each process passes
+// on the larger of that ID and its own, so only the ID of the highest process comes back to it
+func (p *Process) initiateChangRoberts() {
+	p.participated = true
+	fmt.Printf("\033[32mProcess %d is starting a Chang-Roberts election as a candidate.\033[0m\n", p.id)
+	shivizLog.Log(p.id-1, p.vectorClock.Tick(), fmt.Sprintf("Process %d starts an election", p.id))
+	p.pass(Message{kind: ELECTION_MESSAGE, candidate: p.id, term: p.term, initiator: p.id}, 1)
+}
+
+// Function to receive the candidate of a Chang-Roberts election. A process that has already passed on a candidate
+// drops any smaller one, so that at most one candidate goes round the ring after it
+func (p *Process) receiveCandidate(msg Message) {
+	shivizLog.Log(p.id-1, p.vectorClock.Merge(msg.vectorTimeStamp), fmt.Sprintf("Process %d receives candidate %d", p.id, msg.candidate))
+	switch {
+	case msg.candidate == p.id:
+		// Its ID has gone round the ring, so no active process has a higher one
+		fmt.Printf("\033[32mProcess %d got its own ID back.\033[0m\n", p.id)
+		p.announceCoordinator(p.ringOf(p.ring), p.id)
+	case msg.candidate > p.id:
+		p.participated = true
+		p.pass(Message{kind: ELECTION_MESSAGE, candidate: msg.candidate, term: msg.term, initiator: msg.initiator}, 1)
+	case p.participated:
+		fmt.Printf("\033[32mProcess %d drops candidate %d, it has passed on a higher one.\033[0m\n", p.id, msg.candidate)
+	default:
+		fmt.Printf("\033[32mProcess %d replaces candidate %d with itself.\033[0m\n", p.id, msg.candidate)
+		p.participated = true
+		p.pass(Message{kind: ELECTION_MESSAGE, candidate: p.id, term: msg.term, initiator: msg.initiator}, 1)
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Function for a process to start a Hirschberg-Sinclair election by standing as a candidate
+func (p *Process) initiateHirschbergSinclair() {
+	fmt.Printf("\033[32mProcess %d is starting a Hirschberg-Sinclair election as a candidate.\033[0m\n", p.id)
+	shivizLog.Log(p.id-1, p.vectorClock.Tick(), fmt.Sprintf("Process %d starts an election", p.id))
+	p.standAsCandidate()
+}
+
+// Function for a process to stand as a candidate of a Hirschberg-Sinclair election, from phase 0 on
+func (p *Process) standAsCandidate() {
+	p.participated = true
+	p.phase, p.replies = 0, 0
+	p.sendProbes()
+}
+
+// Function for a candidate to send its ID 2^phase processes each way round the ring. It goes on to the next phase once
+// both have come back, so it takes O(n log n) messages in all for the highest candidate to get its ID back. Every
+// phase shows that the election still goes on, so the candidate does not start another one
+func (p *Process) sendProbes() {
+	p.electingSince = scheduler.Now()
+	fmt.Printf("\033[32mProcess %d is a candidate in phase %d, sending its ID %d processes each way.\033[0m\n", p.id, p.phase, 1<<p.phase)
+	probe := Message{kind: ELECTION_MESSAGE, candidate: p.id, phase: p.phase, hops: 1, term: p.term, initiator: p.initiator}
+	p.forward(probe)
+	probe.backward = true
+	p.forward(probe)
+}
+
+// Function to receive the ID of a Hirschberg-Sinclair candidate. A process with a higher ID stops it and stands as a
+// candidate itself, the others pass it on until it has gone 2^phase processes and then send it back
+func (p *Process) receiveProbe(msg Message) {
+	shivizLog.Log(p.id-1, p.vectorClock.Merge(msg.vectorTimeStamp), fmt.Sprintf("Process %d receives candidate %d", p.id, msg.candidate))
+	switch {
+	case msg.candidate == p.id:
+		// Its ID has gone round the ring, so no active process has a higher one
+		fmt.Printf("\033[32mProcess %d got its own ID back in phase %d.\033[0m\n", p.id, msg.phase)
+		p.announceCoordinator(p.ringOf(p.ring), p.id)
+	case msg.candidate < p.id:
+		fmt.Printf("\033[32mProcess %d stops candidate %d.\033[0m\n", p.id, msg.candidate)
+		if !p.participated {
+			p.standAsCandidate()
+		}
+	case msg.hops < 1<<msg.phase:
+		p.participated = true
+		msg.hops++
+		p.forward(msg)
+	default:
+		p.participated = true
+		p.forward(Message{kind: REPLY_MESSAGE, candidate: msg.candidate, phase: msg.phase, hops: msg.hops, backward: !msg.backward, term: msg.term, initiator: msg.initiator})
+	}
+}
+
+// Function to receive a Hirschberg-Sinclair candidate's ID on its way back to it. Its hops count down the processes
+// between it and the candidate, so that it is dropped once it has gone past a candidate that has crashed
+func (p *Process) receiveReply(msg Message) {
+	shivizLog.Log(p.id-1, p.vectorClock.Merge(msg.vectorTimeStamp), fmt.Sprintf("Process %d receives the reply to candidate %d", p.id, msg.candidate))
+	if msg.candidate != p.id {
+		if msg.hops--; msg.hops > 0 {
+			p.forward(msg)
+		}
+		return
+	}
+	if msg.phase != p.phase {
+		return // The reply belongs to an earlier phase
+	}
+	p.replies++
+	if p.replies == 2 {
+		p.phase, p.replies = p.phase+1, 0
+		p.sendProbes()
+	}
+}
+
+// Function to pass a Hirschberg-Sinclair message on in its direction round the ring
+func (p *Process) forward(msg Message) {
+	if !msg.backward {
+		p.pass(msg, 1)
+		return
+	}
+	backward := slices.Clone(p.ring)
+	slices.Reverse(backward[1:])
+	p.passAlong(msg, backward, 1)
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"encoding/binary"
+	"sync"
+	"time"
+)
+
+// Kinds of messages between processes
+const (
+	ELECTION_MESSAGE            = 1  // Ring of an election, passed from process to process, or the challenge of a Bully election
+	ACK_MESSAGE                 = 2  // The receiver has taken over the message passed on as attempt, data holds its kind
+	COORDINATOR_MESSAGE         = 3  // Announcement of the new coordinator and ring structure, passed round the ring or sent to all
+	DATA_MESSAGE                = 4  // Data of the coordinator, term holds the term in which it was elected
+	TICK_MESSAGE                = 5  // A process's own heartbeat timer has fired, attempt holds the recovery it belongs to
+	ACK_TIMEOUT_MESSAGE         = 6  // A process's own timer for an acknowledgement has fired, attempt holds which one
+	JOIN_MESSAGE                = 7  // A recovered or left out process asks the coordinator to be let into the ring, data holds the request
+	MEMBERSHIP_MESSAGE          = 8  // New ring structure from the coordinator after a process has joined or left, passed round the ring
+	RECOVER_MESSAGE             = 9  // The process has recovered from a crash
+	LEAVE_MESSAGE               = 10 // The process after the sender has left, data holds which one and announcement the sender's latest update
+	ALIVE_MESSAGE               = 11 // Heartbeat of a process to the process before it in the ring
+	OK_MESSAGE                  = 12 // A process with a higher ID takes a Bully election over, attempt echoes the election and term holds its own
+	OK_TIMEOUT_MESSAGE          = 13 // A process's own timer for answers to its Bully election has fired, attempt holds which one
+	COORDINATOR_TIMEOUT_MESSAGE = 14 // A process's own timer for the new coordinator to announce itself has fired
+	REPLY_MESSAGE               = 15 // A Hirschberg-Sinclair candidate's ID on its way back to it
+	REQUEST_VOTE_MESSAGE        = 16 // A Raft candidate asks for the vote of a process in term
+	VOTE_MESSAGE                = 17 // Answer to a RequestVote, data is 1 if the vote is granted and term holds the voter's own
+	APPEND_ENTRIES_MESSAGE      = 18 // Data of the Raft leader and its heartbeat, term holds the term it leads in
+	REJECT_MESSAGE              = 19 // A process turns down the AppendEntries of a leader of an older term, term holds its own
+	ELECTION_TIMEOUT_MESSAGE    = 20 // A process's own Raft election timer has fired, attempt holds which one
+)
+
+// Function to name the messages that are passed round the ring
+func kindName(msg Message) string {
+	switch {
+	case msg.kind == COORDINATOR_MESSAGE:
+		return "announcement"
+	case msg.kind == MEMBERSHIP_MESSAGE:
+		return "membership update"
+	case msg.kind == REPLY_MESSAGE:
+		return "reply"
+	case msg.candidate != 0:
+		return "candidate"
+	}
+	return "ring"
+}
+
+type Message struct {
+	kind            int
+	from            int
+	ring            []int
+	coordinator     int
+	announcer       int // Process that started a coordinator announcement or membership update
+	announcement    int // Number of the announcement or update among those of its announcer
+	data            int
+	attempt         int
+	term            int   // Term of the election, or the one in which the coordinator of the message was elected
+	initiator       int   // Process that started the election of term
+	candidate       int   // ID a Chang-Roberts or Hirschberg-Sinclair election message stands for
+	phase           int   // Hirschberg-Sinclair phase, in which the candidate's ID goes 2^phase processes each way
+	hops            int   // Processes the Hirschberg-Sinclair candidate's ID has gone so far
+	backward        bool  // Whether the message goes round the ring against its direction
+	vectorTimeStamp []int // nil for messages that are not in the ShiViz log
+}
+
+// A message passed round the ring that the next process has not acknowledged yet
+type handover struct {
+	msg  Message
+	ring []int // Ring of the sender when it passed the message on
+	next int   // Position in ring of the process the message was passed to
+}
+
+// Unbounded queue of messages to a process, so that senders never wait for
+// the receiver to catch up
+type mailbox struct {
+	mu    sync.Mutex
+	queue []Message
+	wake  chan struct{}
+}
+
+func newMailbox() *mailbox {
+	return &mailbox{wake: make(chan struct{}, 1)}
+}
+
+func (m *mailbox) put(msg Message) {
+	m.mu.Lock()
+	m.queue = append(m.queue, msg)
+	m.mu.Unlock()
+	select {
+	case m.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Blocks until there is a message
+func (m *mailbox) take() Message {
+	for {
+		m.mu.Lock()
+		if len(m.queue) > 0 {
+			msg := m.queue[0]
+			m.queue = m.queue[1:]
+			m.mu.Unlock()
+			return msg
+		}
+		m.mu.Unlock()
+		<-m.wake
+	}
+}
+
+// Function for a process to handle the messages of its mailbox one at a time, in its own goroutine
+func (p *Process) listen() {
+	for {
+		p.handle(p.inbox.take())
+	}
+}
+
+// Function to hand a message to a process. In a simulation the message is handled by an event of the engine instead
+// of the process's goroutine, so that the run only depends on the seed
+func (p *Process) deliver(msg Message) {
+	if SIMULATE {
+		scheduler.AfterFunc(0, func() { p.handle(msg) })
+		return
+	}
+	p.inbox.put(msg)
+}
+
+// Names of the kinds of messages in the report at the end
+var messageNames = map[int]string{ELECTION_MESSAGE: "ELECTION", OK_MESSAGE: "OK", REPLY_MESSAGE: "REPLY", COORDINATOR_MESSAGE: "COORDINATOR", ACK_MESSAGE: "ACK", REQUEST_VOTE_MESSAGE: "REQUEST_VOTE", VOTE_MESSAGE: "VOTE"}
+
+// Function to tell whether a message belongs to an election rather than to the data, heartbeats or membership
+func electionMessage(msg Message) bool {
+	switch msg.kind {
+	case ELECTION_MESSAGE, OK_MESSAGE, REPLY_MESSAGE, COORDINATOR_MESSAGE, REQUEST_VOTE_MESSAGE, VOTE_MESSAGE:
+		return true
+	case ACK_MESSAGE:
+		return msg.data == ELECTION_MESSAGE || msg.data == REPLY_MESSAGE || msg.data == COORDINATOR_MESSAGE
+	}
+	return false
+}
+
+// Function to count the bytes a message takes on the wire, encoded as protocol buffers would: a byte to tell each field
+// that is set and every number as a varint. The vector timestamp is only there for the ShiViz log and does not count
+func (m Message) size() int {
+	n := 0
+	for _, v := range []int{m.kind, m.from, m.coordinator, m.announcer, m.announcement, m.data, m.attempt, m.term, m.initiator, m.candidate, m.phase, m.hops} {
+		if v != 0 {
+			n += 1 + varintSize(v)
+		}
+	}
+	if m.backward {
+		n += 2
+	}
+	if len(m.ring) > 0 {
+		n += 1 + varintSize(len(m.ring))
+		for _, id := range m.ring {
+			n += varintSize(id)
+		}
+	}
+	return n
+}
+
+func varintSize(v int) int {
+	return len(binary.AppendUvarint(nil, uint64(v)))
+}
+
+// Function to count an election message for the report at the end and for the election in progress
+func countMessage(msg Message) {
+	if !electionMessage(msg) {
+		return
+	}
+	size := msg.size()
+	statsMutex.Lock()
+	defer statsMutex.Unlock()
+	sentByKind[msg.kind]++
+	bytesByKind[msg.kind] += size
+	electionSent++
+	electionBytes += size
+}
+
+// Function to send a message to another process through the network. Unless event is empty the send is logged for
+// ShiViz. Under scenario 3B the receiver of an election message may crash just before it arrives
+func (p *Process) send(to int, msg Message, event string) {
+	msg.from = p.id
+	countMessage(msg)
+	crashDuringElection(to, msg)
+	msg.ring = append([]int(nil), msg.ring...)
+	if event != "" {
+		msg.vectorTimeStamp = p.vectorClock.Send()
+		shivizLog.Log(p.id-1, msg.vectorTimeStamp, event)
+	}
+	if process := findProcessByID(to); process != nil {
+		network.Send(p.id, to, func() { process.deliver(msg) })
+	}
+}
+
+// Function for a process to send a message to itself after d
+func (p *Process) after(d time.Duration, msg Message) {
+	msg.from = p.id
+	scheduler.AfterFunc(d, func() { p.deliver(msg) })
+}
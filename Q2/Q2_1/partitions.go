@@ -0,0 +1,203 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Jashveragiwala/Lamport-and-Vector-Clocks/faults"
+)
+
+// A partition of the network splits the processes into groups that cannot reach each other, and each group may elect
+// a coordinator of its own. The run watches for split brain, two or more active processes that each believe they are
+// the coordinator at the same time, and reports how long it lasted and how the data of the processes diverged. Two
+// such processes are only split brain if their overlap starts while the network is partitioned or lasts a whole
+// heartbeat interval; a shorter one is an ordinary handover, where the old coordinator has not yet heard of the new one
+var PARTITIONS string
+var splitMutex sync.Mutex
+var partitioned bool        // Whether the network is partitioned at the moment
+var splitSince time.Time    // When the current overlap of coordinators started, zero if there is none
+var splitCoordinators []int // Processes that have believed they are the coordinator during the current overlap
+var splitCounted bool       // Whether the current overlap counts as split brain yet
+var overlaps int            // Overlaps of coordinators so far, split brain or not
+var splitBrains int
+var splitTime, longestSplit time.Duration
+var mostValues int // Most different values of the data the active processes have held during a split brain
+
+// Function to partition the network and heal it as the partition schedule says
+func partitionNetwork(schedule []faults.Partition) {
+	for _, partition := range schedule {
+		partition := partition
+		scheduler.AfterFunc(partition.At, func() {
+			if runOver() {
+				return
+			}
+			splitMutex.Lock()
+			partitioned = partition.Groups != nil
+			splitMutex.Unlock()
+			if partition.Groups == nil {
+				network.Heal()
+				fmt.Printf("\033[36mThe partition of the network is healed.\033[0m\n")
+				return
+			}
+			network.Partition(partition.Groups)
+			fmt.Printf("\033[31mThe network is partitioned into %v.\033[0m\n", partition.Groups)
+		})
+	}
+}
+
+// Function for a process to make known which coordinator it follows, for the split brain watch. It follows none while
+// it joins, or under Raft while it knows no leader of its term
+func (p *Process) shareView() {
+	view := p.coordinator
+	if p.joining || ELECTION == "raft" && (p.initiator != 0 || p.coordTerm != p.term) {
+		view = 0
+	}
+	p.lock.Lock()
+	p.view = view
+	p.lock.Unlock()
+	checkSplitBrain()
+}
+
+// Function to watch for split brain whenever a process may have changed its mind about the coordinator, crashed or
+// recovered
+func checkSplitBrain() {
+	splitMutex.Lock()
+	defer splitMutex.Unlock()
+	believers := []int{}
+	for _, proc := range processes {
+		proc.lock.Lock()
+		if proc.status == 1 && proc.view == proc.id {
+			believers = append(believers, proc.id)
+		}
+		proc.lock.Unlock()
+	}
+	now := scheduler.Now()
+	switch {
+	case len(believers) > 1 && splitSince.IsZero():
+		splitSince, splitCoordinators, splitCounted = now, believers, false
+		overlaps++
+		if partitioned {
+			startSplitBrain()
+		} else {
+			confirmSplitBrain(overlaps)
+		}
+	case len(believers) > 1:
+		for _, id := range believers {
+			if !slices.Contains(splitCoordinators, id) {
+				splitCoordinators = append(splitCoordinators, id)
+				if splitCounted {
+					fmt.Printf("\033[31mSplit brain: Process %d believes it is the Coordinator as well.\033[0m\n", id)
+				}
+			}
+		}
+	case !splitSince.IsZero() && !splitCounted:
+		splitSince = time.Time{} // A handover, over before it counted as split brain
+	case !splitSince.IsZero():
+		lasted := endSplitBrain(now)
+		fmt.Printf("\033[36mThe split brain of Processes %v is over after %v.\033[0m\n", splitCoordinators, lasted)
+		reportDivergence()
+	}
+}
+
+// Function to count the current overlap of coordinators as split brain
+func startSplitBrain() {
+	splitCounted = true
+	splitBrains++
+	fmt.Printf("\033[31mSplit brain: Processes %v all believe they are the Coordinator.\033[0m\n", splitCoordinators)
+	reportDivergence()
+	watchDivergence(overlaps)
+}
+
+// Function to count an overlap of coordinators that started outside a partition as split brain once it has lasted a
+// heartbeat interval
+func confirmSplitBrain(overlap int) {
+	scheduler.AfterFunc(HEARTBEAT_INTERVAL, func() {
+		splitMutex.Lock()
+		defer splitMutex.Unlock()
+		if overlap == overlaps && !splitSince.IsZero() && !splitCounted {
+			startSplitBrain()
+		}
+	})
+}
+
+// Function to count the current split brain as over at now
+func endSplitBrain(now time.Time) time.Duration {
+	lasted := now.Sub(splitSince)
+	splitSince = time.Time{}
+	splitTime += lasted
+	longestSplit = max(longestSplit, lasted)
+	return lasted
+}
+
+// Function to count how many different values of the data the active processes hold every heartbeat interval while
+// split brain lasts
+func watchDivergence(overlap int) {
+	scheduler.AfterFunc(HEARTBEAT_INTERVAL, func() {
+		splitMutex.Lock()
+		defer splitMutex.Unlock()
+		if overlap != overlaps || splitSince.IsZero() {
+			return // This split brain is over
+		}
+		values, _ := dataByView()
+		mostValues = max(mostValues, values)
+		watchDivergence(overlap)
+	})
+}
+
+// Function to log the data of the active processes, grouped by the coordinator they follow, as PROCESS:DATA
+func reportDivergence() {
+	values, byView := dataByView()
+	mostValues = max(mostValues, values)
+	views := []int{}
+	for view := range byView {
+		views = append(views, view)
+	}
+	slices.Sort(views)
+	slices.Reverse(views)
+	for _, view := range views {
+		if view == 0 {
+			fmt.Printf("\033[33mProcesses that follow no Coordinator hold data %s.\033[0m\n", strings.Join(byView[view], " "))
+		} else {
+			fmt.Printf("\033[33mProcesses that follow Coordinator %d hold data %s.\033[0m\n", view, strings.Join(byView[view], " "))
+		}
+	}
+	fmt.Printf("\033[33mThe active processes hold %d different values of the data.\033[0m\n", values)
+}
+
+// Function to get how many different values of the data the active processes hold, and each one's data as
+// PROCESS:DATA by the coordinator it follows
+func dataByView() (int, map[int][]string) {
+	values := make(map[int]bool)
+	byView := make(map[int][]string)
+	for _, proc := range processes {
+		proc.lock.Lock()
+		if proc.status == 1 {
+			values[proc.data] = true
+			byView[proc.view] = append(byView[proc.view], fmt.Sprintf("%d:%d", proc.id, proc.data))
+		}
+		proc.lock.Unlock()
+	}
+	return len(values), byView
+}
+
+// Function to report how often two processes believed they were the coordinator at the same time, how long it lasted
+// and how far the data diverged meanwhile
+func reportSplitBrain() {
+	splitMutex.Lock()
+	defer splitMutex.Unlock()
+	if !splitSince.IsZero() && splitCounted {
+		lasted := endSplitBrain(scheduler.Now())
+		fmt.Printf("The split brain of Processes %v was still going on at the end, after %v.\n", splitCoordinators, lasted)
+		reportDivergence()
+	}
+	if splitBrains == 0 {
+		if PARTITIONS != "" {
+			fmt.Println("No two processes believed they were the Coordinator at the same time.")
+		}
+		return
+	}
+	fmt.Printf("Split brain happened %d times and lasted %v in total and %v at most, with up to %d different values of the data among the active processes.\n", splitBrains, splitTime.Round(time.Millisecond), longestSplit.Round(time.Millisecond), mostValues)
+}
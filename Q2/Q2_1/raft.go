@@ -0,0 +1,152 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// Under -election raft the processes elect their coordinator, the leader, as Raft does. A follower that hears nothing
+// from a leader before its randomized election timeout runs out stands as a candidate in the next term and asks every
+// process for its vote. Each process votes once in a term, and the candidate with the votes of a majority of all
+// processes leads. The leader's heartbeats are AppendEntries messages that carry its data. There is no ring, no
+// failure detector and no announcement: a process learns the leader from its first AppendEntries
+
+// Function to tell whether the process leads in its term. A recovered process has forgotten that it led
+func (p *Process) leads() bool {
+	return !p.joining && p.coordinator == p.id && p.coordTerm == p.term
+}
+
+// Function to start the election timer of a process afresh, with a timeout drawn between RAFT_TIMEOUT and twice it so
+// that the followers of a crashed leader rarely stand as candidates at once
+func (p *Process) resetElectionTimer() {
+	p.electionTimer++
+	timeout := RAFT_TIMEOUT + time.Duration(random.Intn("raft timeout", int(RAFT_TIMEOUT/time.Millisecond)))*time.Millisecond
+	p.after(timeout, Message{kind: ELECTION_TIMEOUT_MESSAGE, attempt: p.electionTimer})
+}
+
+// Function for a process to stand as a candidate once its election timer has run out. The timer of the leader runs as
+// well, and only starts again
+func (p *Process) raftTimeout(msg Message) {
+	if msg.attempt != p.electionTimer {
+		return // The timer has been started again since
+	}
+	if p.leads() {
+		p.resetElectionTimer()
+		return
+	}
+	var reason string
+	switch {
+	case p.joining:
+		reason = fmt.Sprintf("Process %d heard from no Leader since it recovered", p.id)
+	case p.initiator == p.id:
+		reason = fmt.Sprintf("Process %d got no majority in term %d", p.id, p.term)
+	case p.initiator != 0:
+		reason = fmt.Sprintf("Process %d heard from no Leader in term %d", p.id, p.term)
+	default:
+		_, crashed := p.coordinatorCrashedAt()
+		p.lock.Lock()
+		p.suspicions++
+		if !crashed {
+			p.falseSuspicions++
+		}
+		p.lock.Unlock()
+		shivizLog.Log(p.id-1, p.vectorClock.Tick(), fmt.Sprintf("Process %d suspects that Coordinator %d has crashed", p.id, p.coordinator))
+		reason = fmt.Sprintf("Process %d suspects that Leader %d has crashed after %v without AppendEntries", p.id, p.coordinator, scheduler.Now().Sub(p.lastHeartbeat[p.coordinator]))
+	}
+	p.newTerm()
+	p.votedFor = p.id
+	clear(p.voters)
+	p.voters[p.id] = true
+	fmt.Printf("\033[32m%s, standing as a candidate in term %d.\033[0m\n", reason, p.term)
+	shivizLog.Log(p.id-1, p.vectorClock.Tick(), fmt.Sprintf("Process %d starts an election", p.id))
+	p.resetElectionTimer()
+	for _, proc := range processes {
+		if proc.id != p.id {
+			p.send(proc.id, Message{kind: REQUEST_VOTE_MESSAGE, term: p.term}, fmt.Sprintf("Process %d asks Process %d for its vote", p.id, proc.id))
+		}
+	}
+	p.countVotes()
+}
+
+// Function for a process to take over a newer term that another process has told it of. A leader or candidate of an
+// older term steps down and waits for the leader of the new one
+func (p *Process) raftTerm(term, from int) {
+	if term <= p.term {
+		return
+	}
+	if p.leads() {
+		fmt.Printf("\033[34mLeader %d steps down, Process %d is in term %d.\033[0m\n", p.id, from, term)
+	}
+	p.takePart(term, from)
+	p.votedFor = 0
+}
+
+// Function for a process to answer the RequestVote of a candidate. It grants its vote to the first candidate that asks
+// in a term, and waits for it rather than standing itself
+func (p *Process) receiveRequestVote(msg Message) {
+	shivizLog.Log(p.id-1, p.vectorClock.Merge(msg.vectorTimeStamp), fmt.Sprintf("Process %d receives the RequestVote of Process %d", p.id, msg.from))
+	p.raftTerm(msg.term, msg.from)
+	granted := 0
+	if msg.term == p.term && (p.votedFor == 0 || p.votedFor == msg.from) {
+		granted = 1
+		p.votedFor = msg.from
+		p.resetElectionTimer()
+		fmt.Printf("\033[32mProcess %d votes for Process %d in term %d.\033[0m\n", p.id, msg.from, msg.term)
+	} else {
+		fmt.Printf("\033[32mProcess %d does not vote for Process %d in term %d, it is in term %d and voted for Process %d.\033[0m\n", p.id, msg.from, msg.term, p.term, p.votedFor)
+	}
+	p.send(msg.from, Message{kind: VOTE_MESSAGE, term: p.term, data: granted}, fmt.Sprintf("Process %d answers the RequestVote of Process %d", p.id, msg.from))
+}
+
+// Function for a candidate to count a vote, which may tell it of a newer term instead
+func (p *Process) receiveVote(msg Message) {
+	shivizLog.Log(p.id-1, p.vectorClock.Merge(msg.vectorTimeStamp), fmt.Sprintf("Process %d receives the vote of Process %d", p.id, msg.from))
+	p.raftTerm(msg.term, msg.from)
+	if msg.data == 0 || msg.term != p.term || p.initiator != p.id {
+		return
+	}
+	p.voters[msg.from] = true
+	p.countVotes()
+}
+
+// Function for a candidate to lead once a majority of all processes, crashed or not, has voted for it
+func (p *Process) countVotes() {
+	if p.initiator != p.id || len(p.voters) <= len(processes)/2 {
+		return
+	}
+	fmt.Printf("\033[34mProcess %d is elected as the new Coordinator with %d of %d votes in term %d.\033[0m\n", p.id, len(p.voters), len(processes), p.term)
+	shivizLog.Log(p.id-1, p.vectorClock.Tick(), fmt.Sprintf("Process %d elects itself as Coordinator", p.id))
+	p.takeCoordinator(p.id, p.term)
+	p.endElection(p.term)
+	p.sendAppendEntries()
+}
+
+// Function for the leader to send its data to all other processes as the heartbeat of its term
+func (p *Process) sendAppendEntries() {
+	p.lock.Lock()
+	data := p.data
+	p.lock.Unlock()
+	for _, proc := range processes {
+		if proc.id != p.id {
+			fmt.Printf("Coordinator %d is sending data %d to Process %d.\n", p.id, data, proc.id)
+			p.send(proc.id, Message{kind: APPEND_ENTRIES_MESSAGE, data: data, term: p.term}, fmt.Sprintf("Coordinator %d sends data %d to Process %d", p.id, data, proc.id))
+		}
+	}
+}
+
+// Function for a process to follow the leader that sent AppendEntries and take over its data. A leader of an older term
+// is turned down and told of the newer one
+func (p *Process) receiveAppendEntries(msg Message) {
+	if msg.term < p.term {
+		fmt.Printf("\033[33mProcess %d turns down the data of Coordinator %d from term %d, it is in term %d.\033[0m\n", p.id, msg.from, msg.term, p.term)
+		p.send(msg.from, Message{kind: REJECT_MESSAGE, term: p.term}, "")
+		return
+	}
+	p.raftTerm(msg.term, msg.from)
+	if p.joining || p.initiator != 0 || p.coordinator != msg.from || p.coordTerm != msg.term {
+		p.takeCoordinator(msg.from, msg.term)
+	}
+	p.heartbeat(msg.from)
+	p.resetElectionTimer()
+	p.receiveData(msg)
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/Jashveragiwala/Lamport-and-Vector-Clocks/detector"
+)
+
+// Function to recover a crashed process, which then asks to be let back into the ring
+func recoverProcess(id int) {
+	proc := findProcessByID(id)
+	if proc == nil {
+		return
+	}
+	proc.lock.Lock()
+	if proc.status == 1 {
+		proc.lock.Unlock()
+		return
+	}
+	proc.status, proc.view = 1, 0 // It follows no coordinator until it has rejoined
+	proc.lock.Unlock()
+	shivizLog.Log(proc.id-1, proc.vectorClock.Tick(), fmt.Sprintf("Process %d recovers", id))
+	fmt.Printf("\033[36mProcess %d recovered.\033[0m\n", id)
+	proc.deliver(Message{kind: RECOVER_MESSAGE})
+}
+
+// Function for a recovered process to start again. It remembers its data, the ring and the highest term it knew of, but
+// whatever it was waiting for before the crash is over and the coordinator it knew of may have changed, so it asks to
+// join the ring
+func (p *Process) rejoin() {
+	p.recoveries++
+	p.electing, p.initiator = false, 0
+	p.handovers = make(map[int]*handover)
+	p.detectors = make(map[int]detector.Detector)
+	if ELECTION == "raft" {
+		// There is no ring to join, the process follows the first leader it hears from
+		p.joining, p.joiningSince = true, scheduler.Now()
+		p.shareView()
+		p.resetElectionTimer()
+	} else {
+		p.startJoining()
+	}
+	p.run()
+}
+
+// Function for a process to start asking to join the ring, after a recovery or when an election has left it out
+func (p *Process) startJoining() {
+	p.leave()
+	p.requestJoin()
+}
+
+// Function for a process to stop following its coordinator, or to stop being the coordinator, until a coordinator lets
+// it into its ring
+func (p *Process) leave() {
+	p.joins++
+	p.joining, p.joinRequests, p.suspected, p.joiningSince = true, 0, false, scheduler.Now()
+	p.shareView()
+}
+
+// Function for a recovered process to ask the coordinator to let it back into the ring. It does not know which process
+// is the coordinator now, so it asks every process of the ring it knew of and only the coordinator answers. After
+// JOIN_ATTEMPTS heartbeat intervals without an answer it starts an election instead, and asks again if the election
+// leaves it out
+func (p *Process) requestJoin() {
+	if p.joinRequests == JOIN_ATTEMPTS {
+		p.joinRequests = 0
+		p.startElection(fmt.Sprintf("Process %d got no answer to its requests to join the ring", p.id))
+		return
+	}
+	p.joinRequests++
+	fmt.Printf("\033[36mProcess %d asks to join the ring (attempt %d).\033[0m\n", p.id, p.joinRequests)
+	for _, id := range p.ring[1:] {
+		p.send(id, Message{kind: JOIN_MESSAGE, data: p.joins}, fmt.Sprintf("Process %d asks Process %d to join the ring", p.id, id))
+	}
+}
+
+// Function for the coordinator to let a recovered process back into the ring, in the order of the IDs. The process gets
+// the data straight away, and the new ring structure goes round the ring
+func (p *Process) admit(msg Message) {
+	shivizLog.Log(p.id-1, p.vectorClock.Merge(msg.vectorTimeStamp), fmt.Sprintf("Coordinator %d receives the request of Process %d to join", p.id, msg.from))
+	if p.admitted[msg.from] >= msg.data {
+		return // Already let in after this request
+	}
+	p.admitted[msg.from] = msg.data
+	members := slices.Clone(p.ring)
+	if !slices.Contains(members, msg.from) {
+		members = append(members, msg.from)
+	}
+	ring := p.ringOf(members)
+	fmt.Printf("\033[36mCoordinator %d lets Process %d back into the ring: %v\033[0m\n", p.id, msg.from, ring)
+
+	p.lock.Lock()
+	data := p.data
+	p.lock.Unlock()
+	fmt.Printf("Coordinator %d is sending data %d to Process %d.\n", p.id, data, msg.from)
+	p.send(msg.from, Message{kind: DATA_MESSAGE, data: data, term: p.coordTerm}, fmt.Sprintf("Coordinator %d sends data %d to Process %d", p.id, data, msg.from))
+
+	p.announceMembership(ring)
+	p.joinedIn[msg.from] = p.announcements
+}
+
+// Function for the coordinator to order processes into a ring by their IDs, starting at itself
+func (p *Process) ringOf(members []int) []int {
+	members = slices.Clone(members)
+	slices.Sort(members)
+	i := slices.Index(members, p.id)
+	return append(append([]int{}, members[i:]...), members[:i]...)
+}
+
+// Function for the coordinator to pass a new ring structure round the ring. Every change to the ring structure outside
+// of an election goes through the coordinator, so that two of them cannot cross and undo one another
+func (p *Process) announceMembership(ring []int) {
+	p.announcements++
+	update := Message{kind: MEMBERSHIP_MESSAGE, ring: ring, coordinator: p.id, announcer: p.id, announcement: p.announcements, term: p.coordTerm}
+	p.learnCoordinator(update)
+	p.pass(update, 1)
+}
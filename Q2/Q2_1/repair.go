@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Function to get the process after this one in its ring, or 0 if it is alone
+func (p *Process) successor() int {
+	if len(p.ring) < 2 {
+		return 0
+	}
+	return p.ring[1]
+}
+
+// Function for a process to monitor the process after it in the ring besides the coordinator, and no other. It starts
+// afresh with every new ring structure, since the process after it may not have known until now that it should send
+// its heartbeats here
+func (p *Process) watchSuccessor() {
+	next := p.successor()
+	for id := range p.detectors {
+		if id != p.coordinator && id != next {
+			delete(p.detectors, id)
+		}
+	}
+	if next != 0 && next != p.coordinator {
+		p.monitor(next)
+	}
+}
+
+// Function for a process to send a heartbeat to the process before it in the ring, which monitors it
+func (p *Process) sendAlive() {
+	if len(p.ring) > 1 {
+		p.send(p.ring[len(p.ring)-1], Message{kind: ALIVE_MESSAGE}, "")
+	}
+}
+
+// Function to check on the process after this one in the ring. If its failure detector suspects it has crashed, the
+// process splices it out of its own ring straight away and tells the coordinator, which passes the new ring structure
+// round the ring. The coordinator itself is left to the election
+func (p *Process) checkSuccessorStatus() {
+	next := p.successor()
+	if next == 0 || next == p.coordinator || p.suspected {
+		return
+	}
+	now := scheduler.Now()
+	d := p.detectors[next]
+	if !d.Suspect(now) {
+		return
+	}
+	_, crashed := p.crashedSinceHeartbeat(next)
+	p.lock.Lock()
+	p.splices++
+	if !crashed && findProcessByID(next).active() {
+		p.falseSplices++
+	}
+	p.lock.Unlock()
+	fmt.Printf("\033[36mProcess %d suspects that Process %d after it in the ring has crashed after %v without a heartbeat (suspicion level %.2f), splicing it out of the ring.\033[0m\n", p.id, next, now.Sub(p.lastHeartbeat[next]), d.Level(now))
+	shivizLog.Log(p.id-1, p.vectorClock.Tick(), fmt.Sprintf("Process %d suspects that Process %d has crashed", p.id, next))
+	if p.id == p.coordinator {
+		p.removeFromRing(Message{from: p.id, data: next, announcement: p.announcements})
+		return
+	}
+	p.ring = slices.DeleteFunc(slices.Clone(p.ring), func(id int) bool { return id == next })
+	p.watchSuccessor()
+	p.send(p.coordinator, Message{kind: LEAVE_MESSAGE, data: next, announcement: p.announced[p.coordinator]}, fmt.Sprintf("Process %d tells Coordinator %d that Process %d has left", p.id, p.coordinator, next))
+}
+
+// Function for the coordinator to splice a process out of the ring once the process before it has noticed that it
+// left. A process that has been let back in since the reporter last heard of the ring structure stays
+func (p *Process) removeFromRing(msg Message) {
+	id := msg.data
+	if !slices.Contains(p.ring, id) {
+		return // Already spliced out
+	}
+	if p.joinedIn[id] > msg.announcement {
+		fmt.Printf("\033[36mCoordinator %d keeps Process %d in the ring, it has joined again since Process %d last heard of the ring.\033[0m\n", p.id, id, msg.from)
+		return
+	}
+	ring := slices.DeleteFunc(slices.Clone(p.ring), func(member int) bool { return member == id })
+	fmt.Printf("\033[36mCoordinator %d splices Process %d out of the ring on behalf of Process %d: %v\033[0m\n", p.id, id, msg.from, ring)
+	p.announceMembership(ring)
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+)
+
+// The crash scenarios of Q2_3A and Q2_3B crash a process at a point of an election rather than at a time of the crash
+// schedule. -scenario runs them on top of the crash schedule with any election algorithm, so that the algorithms can be
+// compared under them:
+//   - 3A: the new coordinator crashes while it is being announced, once the processes at a random number of steps after
+//     it have learned of it. It crashes once in a run, and in a later election if there were fewer steps
+//   - 3B: a process other than the coordinator crashes on a coin toss as an election reaches it, at most once in a term
+var SCENARIO string
+var SCENARIOS = []string{"3A", "3B"}
+var scenarioMutex sync.Mutex
+var announcedCrashed bool // Whether 3A has crashed a new coordinator
+var announcedTerm int     // Term whose announcement 3A follows
+var learned, crashStep int
+var electionCrashTerm int // Latest term in which 3B has crashed a process
+
+// Function to crash the new coordinator under scenario 3A once enough processes have learned of it. It counts the
+// processes from the coordinator itself, which may not be the first to learn
+func crashWhileAnnounced(newCoordinator, term int) {
+	if SCENARIO != "3A" || currentCoordinator().id != newCoordinator {
+		return
+	}
+	scenarioMutex.Lock()
+	if announcedCrashed {
+		scenarioMutex.Unlock()
+		return
+	}
+	if term != announcedTerm {
+		announcedTerm, learned, crashStep = term, 0, random.Intn("crash point", len(processes))
+	}
+	learned++
+	crash := learned > crashStep
+	announcedCrashed = crash
+	scenarioMutex.Unlock()
+	if crash {
+		crashProcess(newCoordinator)
+		fmt.Printf("\033[31mCoordinator %d crashed while it was being announced.\033[0m\n", newCoordinator)
+	}
+}
+
+// Function to crash the receiver of an election message under scenario 3B, on a coin toss, unless it is the
+// coordinator or a process has crashed during this term already
+func crashDuringElection(to int, msg Message) {
+	if SCENARIO != "3B" || msg.kind != ELECTION_MESSAGE && msg.kind != REQUEST_VOTE_MESSAGE || to == currentCoordinator().id {
+		return
+	}
+	if proc := findProcessByID(to); proc == nil || !proc.active() {
+		return
+	}
+	scenarioMutex.Lock()
+	crash := msg.term > electionCrashTerm && random.Intn("crash during election", 2) == 0
+	if crash {
+		electionCrashTerm = msg.term
+	}
+	scenarioMutex.Unlock()
+	if crash {
+		crashProcess(to)
+		fmt.Printf("\033[31mNon-coordinator Process %d crashed during election.\033[0m\n", to)
+	}
+}
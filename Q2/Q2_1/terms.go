@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"math/bits"
+	"time"
+)
+
+// Every election is tagged with a term and the process that started it. A process starts an election in the term after
+// the highest one it has seen, and each process takes part in one election at a time: that of the highest term, and in
+// a term that of the initiator with the highest ID. Messages of any other election are dropped, so concurrent elections
+// extinguish one another until one is left, and an election that is over can no longer undo the one after it
+
+// Function for a process to tell whether it takes part in an election, rather than knowing the coordinator. An election
+// that has not ended after electionTimeout was lost with a process that crashed, and the process may start another one
+func (p *Process) inElection() bool {
+	return p.initiator != 0 && scheduler.Now().Sub(p.electingSince) < electionTimeout()
+}
+
+// Function for the longest time an election can take, when every process but one has crashed on the way. A
+// Hirschberg-Sinclair election goes past them once in every phase
+func electionTimeout() time.Duration {
+	timeout := time.Duration(2*len(processes))*ACK_TIMEOUT + HEARTBEAT_INTERVAL
+	if ELECTION == "hirschberg-sinclair" {
+		timeout *= time.Duration(bits.Len(uint(len(processes)-1)) + 1)
+	}
+	return timeout
+}
+
+// Function for a process to start an election of its own, in a new term
+func (p *Process) newTerm() {
+	p.term++
+	p.takePart(p.term, p.id)
+	statsMutex.Lock()
+	electionsStarted++
+	statsMutex.Unlock()
+}
+
+// Function for a process to take part in the election of term started by initiator, afresh
+func (p *Process) takePart(term, initiator int) {
+	p.term, p.initiator, p.electingSince = term, initiator, scheduler.Now()
+	p.participated, p.phase, p.replies = false, 0, 0
+	p.shareView()
+}
+
+// Function for a process to decide whether a message passed round the ring belongs to the election it takes part in. A
+// message of a higher term, or of the same term from an initiator with a higher ID, starts a newer election and the
+// process takes part in that one from now on
+func (p *Process) joinElection(msg Message) bool {
+	switch {
+	case msg.term < p.term || msg.term == p.term && p.initiator == 0:
+		fmt.Printf("\033[32mProcess %d drops the %s of Process %d from term %d, it is in term %d.\033[0m\n", p.id, kindName(msg), msg.initiator, msg.term, p.term)
+		return false
+	case msg.term == p.term && msg.initiator < p.initiator:
+		fmt.Printf("\033[32mProcess %d extinguishes the election of Process %d in term %d, it takes part in that of Process %d.\033[0m\n", p.id, msg.initiator, msg.term, p.initiator)
+		return false
+	case msg.term > p.term || msg.initiator > p.initiator:
+		p.takePart(msg.term, msg.initiator)
+	}
+	return true
+}
+
+// Function to tell whether a message passed round the ring belongs to the election the process takes part in
+func (p *Process) current(msg Message) bool {
+	return msg.term == p.term && msg.initiator == p.initiator
+}
+
+// Function to tell whether a coordinator of term is older than the election the process takes part in or the
+// coordinator it knows of. Of two coordinators of the same term the one with the higher ID wins
+func (p *Process) olderCoordinator(term, id int) bool {
+	return term < p.term || term == p.coordTerm && id < p.coordinator
+}
+
+// Function to tell whether a coordinator of term is newer than the coordinator the process knows of
+func (p *Process) newerCoordinator(term, id int) bool {
+	return term > p.coordTerm || term == p.coordTerm && id > p.coordinator
+}
@@ -1,11 +1,18 @@
 package main
 
 import (
+	"flag"
 	"fmt"
-	"math/rand"
 	"os"
 	"sync"
 	"time"
+
+	"github.com/Jashveragiwala/Lamport-and-Vector-Clocks/chance"
+	"github.com/Jashveragiwala/Lamport-and-Vector-Clocks/clock"
+	"github.com/Jashveragiwala/Lamport-and-Vector-Clocks/config"
+	"github.com/Jashveragiwala/Lamport-and-Vector-Clocks/faults"
+	"github.com/Jashveragiwala/Lamport-and-Vector-Clocks/shiviz"
+	"github.com/Jashveragiwala/Lamport-and-Vector-Clocks/sim"
 )
 
 type Process struct {
@@ -15,9 +22,17 @@ type Process struct {
 	lock    sync.Mutex
 	elected bool
 	ring    []int
+
+	vectorClock *clock.VectorClock // Index id-1, only used for the ShiViz log
 }
 
 var processes []*Process
+var SHIVIZ_FILE string
+var shivizLog *shiviz.Logger // nil unless a ShiViz log was requested
+var SEED int64
+var random *chance.Source // Makes every random decision
+var HEARTBEAT_INTERVAL = 4 * time.Second
+var CRASHES = "coordinator@10s,random@15s/5s"
 var wg sync.WaitGroup
 var coordinator *Process
 var electionInProgress = false
@@ -29,12 +44,12 @@ func (p *Process) run() {
 	for {
 		if p.status == 1 {
 			if p.id == coordinator.id {
-				time.Sleep(4 * time.Second)
+				time.Sleep(HEARTBEAT_INTERVAL)
 				if p.status == 1 {
 					p.sendDataToProcesses()
 				}
 			} else {
-				time.Sleep(4 * time.Second)
+				time.Sleep(HEARTBEAT_INTERVAL)
 				p.checkCoordinatorStatus()
 			}
 		}
@@ -47,10 +62,13 @@ func (p *Process) sendDataToProcesses() {
 	for _, proc := range processes {
 		if proc.status == 1 && proc.id != p.id {
 			fmt.Printf("Coordinator %d is sending data %d to Process %d.\n", p.id, p.data, proc.id)
+			timestamp := p.vectorClock.Send()
+			shivizLog.Log(p.id-1, timestamp, fmt.Sprintf("Coordinator %d sends data %d to Process %d", p.id, p.data, proc.id))
 			proc.lock.Lock()
 			if proc.status == 1 {
 				proc.data = p.data
 				fmt.Printf("Process %d updated its data to: %d (received from Coordinator %d)\n", proc.id, proc.data, p.id)
+				shivizLog.Log(proc.id-1, proc.vectorClock.Merge(timestamp), fmt.Sprintf("Process %d updates its data to %d", proc.id, proc.data))
 			}
 			proc.lock.Unlock()
 		}
@@ -63,6 +81,7 @@ func (p *Process) checkCoordinatorStatus() {
 		if !electionInProgress {
 			electionInProgress = true
 			fmt.Printf("\033[32mProcess %d detects that Coordinator %d has crashed, initiating election.\033[0m\n", p.id, coordinator.id)
+			shivizLog.Log(p.id-1, p.vectorClock.Tick(), fmt.Sprintf("Process %d detects that Coordinator %d has crashed", p.id, coordinator.id))
 			go p.initiateElection()
 		}
 		electionMutex.Unlock()
@@ -72,6 +91,7 @@ func (p *Process) checkCoordinatorStatus() {
 func (p *Process) initiateElection() {
 	electionRing := []int{p.id}
 	fmt.Printf("\033[32mProcess %d is starting the election, initial ring: %v\033[0m\n", p.id, electionRing)
+	shivizLog.Log(p.id-1, p.vectorClock.Tick(), fmt.Sprintf("Process %d starts an election", p.id))
 	p.sendRingToNextActiveProcess(electionRing)
 }
 
@@ -81,7 +101,7 @@ func (p *Process) sendRingToNextActiveProcess(ring []int) {
 		nextProcess := findProcessByID(nextProcessID)
 		if nextProcess != nil && nextProcess.status == 1 {
 			// Introduce a forced crash of a random non-coordinator process during election
-			if !crashedDuringElection && nextProcess.id != coordinator.id && rand.Intn(2) == 0 {
+			if !crashedDuringElection && nextProcess.id != coordinator.id && random.Intn("crash during election", 2) == 0 {
 				crashProcess(nextProcess.id)
 				crashedDuringElection = true
 				fmt.Printf("\033[31mNon-coordinator Process %d crashed during election.\033[0m\n", nextProcess.id)
@@ -89,14 +109,17 @@ func (p *Process) sendRingToNextActiveProcess(ring []int) {
 			}
 
 			fmt.Printf("\033[32mProcess %d passing ring %v to Process %d\033[0m\n", p.id, ring, nextProcess.id)
-			nextProcess.receiveRing(ring)
+			timestamp := p.vectorClock.Send()
+			shivizLog.Log(p.id-1, timestamp, fmt.Sprintf("Process %d passes ring %v to Process %d", p.id, ring, nextProcess.id))
+			nextProcess.receiveRing(ring, timestamp)
 			return
 		}
 	}
 	fmt.Printf("\033[32mProcess %d could not find any active process to pass the ring.\033[0m\n", p.id)
 }
 
-func (p *Process) receiveRing(ring []int) {
+func (p *Process) receiveRing(ring []int, timestamp []int) {
+	shivizLog.Log(p.id-1, p.vectorClock.Merge(timestamp), fmt.Sprintf("Process %d receives ring %v", p.id, ring))
 	for _, id := range ring {
 		if id == p.id {
 			fmt.Printf("\033[32mProcess %d found its ID in the ring. Updating new ring structure: %v\033[0m\n", p.id, ring)
@@ -119,6 +142,13 @@ func (p *Process) updateRing(newRing []int) {
 			process.ring = modifiedRing
 			process.lock.Unlock()
 			fmt.Printf("\033[32mProcess %d updated with new ring structure: %v\033[0m\n", process.id, modifiedRing)
+			if process == p {
+				shivizLog.Log(p.id-1, p.vectorClock.Tick(), fmt.Sprintf("Process %d updates its ring to %v", p.id, modifiedRing))
+			} else {
+				timestamp := p.vectorClock.Send()
+				shivizLog.Log(p.id-1, timestamp, fmt.Sprintf("Process %d sends ring %v to Process %d", p.id, modifiedRing, process.id))
+				shivizLog.Log(process.id-1, process.vectorClock.Merge(timestamp), fmt.Sprintf("Process %d updates its ring to %v", process.id, modifiedRing))
+			}
 		}
 	}
 
@@ -133,6 +163,7 @@ func (p *Process) updateRing(newRing []int) {
 		newCoordinator.elected = true
 		coordinator = newCoordinator
 		fmt.Printf("\033[34mProcess %d is elected as the new Coordinator.\033[0m\n", newCoordinator.id)
+		shivizLog.Log(p.id-1, p.vectorClock.Tick(), fmt.Sprintf("Process %d elects Process %d as Coordinator", p.id, newCoordinator.id))
 	}
 	electionInProgress = false
 	crashedDuringElection = false // Reset for next election
@@ -143,6 +174,7 @@ func (p *Process) updateData(newData int) {
 	defer p.lock.Unlock()
 	p.data = newData
 	fmt.Printf("\033[33mProcess %d changed its data to: %d\033[0m\n", p.id, newData)
+	shivizLog.Log(p.id-1, p.vectorClock.Tick(), fmt.Sprintf("Process %d changes its data to %d", p.id, newData))
 }
 
 func findProcessByID(id int) *Process {
@@ -160,6 +192,7 @@ func crashProcess(id int) {
 			proc.lock.Lock()
 			proc.status = 0
 			proc.lock.Unlock()
+			shivizLog.Log(proc.id-1, proc.vectorClock.Tick(), fmt.Sprintf("Process %d crashes", id))
 			fmt.Printf("\033[31mProcess %d crashed.\033[0m\n", id)
 			break
 		}
@@ -175,14 +208,57 @@ func allProcessesCrashed() bool {
 	return true
 }
 
+// Ends the run once every process has crashed. A process can also crash during an election, so this is checked before
+// every scheduled crash as well as after it
+func endRunIfAllCrashed() bool {
+	if allProcessesCrashed() {
+		fmt.Println("\033[31mAll processes have ended. Terminating program.\033[0m")
+		if err := shivizLog.Close(); err != nil {
+			fmt.Printf("Could not write ShiViz log: %v\n", err)
+		}
+		os.Exit(0)
+	}
+	return false
+}
+
 func main() {
-	rand.Seed(time.Now().UnixNano())
+	flag.StringVar(&SHIVIZ_FILE, "shiviz", "", "write a log for the ShiViz visualiser to this file")
+	flag.Int64Var(&SEED, "seed", 0, "seed of every random decision, taken from the clock when 0")
+	record := flag.String("record", "", "record every random decision to this JSON Lines file")
+	replay := flag.String("replay", "", "repeat the random decisions recorded in this file, as long as the run asks for the same ones")
 	var numProcesses int
-	fmt.Print("\033[38;5;88mEnter the number of processes: \033[0m")
-	fmt.Scanln(&numProcesses)
+	flag.IntVar(&numProcesses, "processes", 0, "number of processes, asked for when not given")
+	flag.DurationVar(&HEARTBEAT_INTERVAL, "heartbeat", HEARTBEAT_INTERVAL, "time between two rounds of the coordinator sending its data and the others checking on it")
+	flag.StringVar(&CRASHES, "crashes", CRASHES, faults.Usage)
+	configFile := flag.String("config", "", config.Usage)
+	flag.Parse()
+	if err := config.Load(flag.CommandLine, *configFile); err != nil {
+		fmt.Println(err)
+		os.Exit(2)
+	}
+	crashes, err := faults.Parse(CRASHES)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(2)
+	}
+	// Every random decision comes from one source, so that a run can be repeated from its seed or its recording
+	random, err = chance.Open(SEED, *record, *replay)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(2)
+	}
+	SEED = random.Seed()
+	if numProcesses == 0 {
+		fmt.Print("\033[38;5;88mEnter the number of processes: \033[0m")
+		fmt.Scanln(&numProcesses)
+	}
+	if numProcesses < 1 {
+		fmt.Println("You need at least 1 process.")
+		os.Exit(2)
+	}
 
 	for i := 1; i <= numProcesses; i++ {
-		processes = append(processes, &Process{id: i, status: 1, data: rand.Intn(100)})
+		processes = append(processes, &Process{id: i, status: 1, data: random.Intn("data", 100), vectorClock: clock.NewVectorClock(i-1, numProcesses)})
 	}
 
 	for i := 0; i < numProcesses; i++ {
@@ -192,6 +268,18 @@ func main() {
 		}
 	}
 
+	if SHIVIZ_FILE != "" {
+		hosts := make([]string, numProcesses)
+		for i := range hosts {
+			hosts[i] = fmt.Sprintf("Process%d", i+1)
+		}
+		shivizLog, err = shiviz.Create(SHIVIZ_FILE, hosts)
+		if err != nil {
+			fmt.Printf("Could not create ShiViz log: %v\n", err)
+		}
+		shivizLog.CloseOnInterrupt()
+	}
+
 	coordinator = processes[0]
 	for _, proc := range processes {
 		if proc.id > coordinator.id {
@@ -206,22 +294,20 @@ func main() {
 		go proc.run()
 	}
 
-	go func() {
-		time.Sleep(10 * time.Second)
-		crashProcess(coordinator.id)
-		fmt.Printf("\033[31mCoordinator Process %d crashed forcefully.\033[0m\n", coordinator.id)
-
-		// Randomly and slowly crash remaining processes
-		for {
-			if allProcessesCrashed() {
-				fmt.Println("\033[31mAll processes have ended. Terminating program.\033[0m")
-				os.Exit(0)
+	// By default the coordinator crashes forcefully, then the remaining processes randomly and slowly
+	faults.Run(crashes, sim.RealTime(), func(c faults.Crash) {
+		active := []int{}
+		for _, proc := range processes {
+			if proc.status == 1 {
+				active = append(active, proc.id)
 			}
-			time.Sleep(5 * time.Second)
-			crashProcess(rand.Intn(numProcesses) + 1)
-
 		}
-	}()
+		crashProcess(c.Choose(numProcesses, active, coordinator.id, random.For("crash").Intn))
+		if c.Process == faults.Coordinator {
+			fmt.Printf("\033[31mCoordinator Process %d crashed forcefully.\033[0m\n", coordinator.id)
+		}
+		endRunIfAllCrashed()
+	}, endRunIfAllCrashed)
 
 	wg.Wait()
 }
@@ -1,11 +1,18 @@
 package main
 
 import (
+	"flag"
 	"fmt"
-	"math/rand"
 	"os"
 	"sync"
 	"time"
+
+	"github.com/Jashveragiwala/Lamport-and-Vector-Clocks/chance"
+	"github.com/Jashveragiwala/Lamport-and-Vector-Clocks/clock"
+	"github.com/Jashveragiwala/Lamport-and-Vector-Clocks/config"
+	"github.com/Jashveragiwala/Lamport-and-Vector-Clocks/faults"
+	"github.com/Jashveragiwala/Lamport-and-Vector-Clocks/shiviz"
+	"github.com/Jashveragiwala/Lamport-and-Vector-Clocks/sim"
 )
 
 type Process struct {
@@ -15,9 +22,17 @@ type Process struct {
 	lock    sync.Mutex
 	elected bool
 	ring    []int
+
+	vectorClock *clock.VectorClock // Index id-1, only used for the ShiViz log
 }
 
 var processes []*Process
+var SHIVIZ_FILE string
+var shivizLog *shiviz.Logger // nil unless a ShiViz log was requested
+var SEED int64
+var random *chance.Source // Makes every random decision
+var HEARTBEAT_INTERVAL = 4 * time.Second
+var CRASHES = "random@10s/20s"
 var wg sync.WaitGroup
 var coordinator *Process
 var electionInProgress = false // Flag to indicate if an election is in progress
@@ -29,13 +44,13 @@ func (p *Process) run() {
 		if p.status == 1 {
 			if p.id == coordinator.id {
 				// The coordinator periodically sends its data to all processes every 4 seconds
-				time.Sleep(4 * time.Second)
+				time.Sleep(HEARTBEAT_INTERVAL)
 				if p.status == 1 { // Ensure the coordinator is still active
 					p.sendDataToProcesses()
 				}
 			} else {
 				// Check if it's been more than 4 seconds since the last data was received
-				time.Sleep(4 * time.Second)
+				time.Sleep(HEARTBEAT_INTERVAL)
 				p.checkCoordinatorStatus()
 			}
 		}
@@ -49,10 +64,13 @@ func (p *Process) sendDataToProcesses() {
 	for _, proc := range processes {
 		if proc.status == 1 && proc.id != p.id {
 			fmt.Printf("Coordinator %d is sending data %d to Process %d.\n", p.id, p.data, proc.id)
+			timestamp := p.vectorClock.Send()
+			shivizLog.Log(p.id-1, timestamp, fmt.Sprintf("Coordinator %d sends data %d to Process %d", p.id, p.data, proc.id))
 			proc.lock.Lock()
 			if proc.status == 1 {
 				proc.data = p.data
 				fmt.Printf("Process %d updated its data to: %d (received from Coordinator %d)\n", proc.id, proc.data, p.id)
+				shivizLog.Log(proc.id-1, proc.vectorClock.Merge(timestamp), fmt.Sprintf("Process %d updates its data to %d", proc.id, proc.data))
 			}
 			proc.lock.Unlock()
 		}
@@ -66,6 +84,7 @@ func (p *Process) checkCoordinatorStatus() {
 		if !electionInProgress {
 			electionInProgress = true
 			fmt.Printf("\033[32mProcess %d detects that Coordinator %d has crashed, initiating election.\033[0m\n", p.id, coordinator.id)
+			shivizLog.Log(p.id-1, p.vectorClock.Tick(), fmt.Sprintf("Process %d detects that Coordinator %d has crashed", p.id, coordinator.id))
 			go p.initiateElection() // Start election from this process
 
 		}
@@ -78,6 +97,7 @@ func (p *Process) checkCoordinatorStatus() {
 func (p *Process) initiateElection() {
 	electionRing := []int{p.id}
 	fmt.Printf("\033[32mProcess %d is starting the election, initial ring: %v\033[0m\n", p.id, electionRing)
+	shivizLog.Log(p.id-1, p.vectorClock.Tick(), fmt.Sprintf("Process %d starts an election", p.id))
 	p.sendRingToNextActiveProcess(electionRing)
 }
 
@@ -88,7 +108,9 @@ func (p *Process) sendRingToNextActiveProcess(ring []int) {
 		nextProcess := findProcessByID(nextProcessID)
 		if nextProcess != nil && nextProcess.status == 1 {
 			fmt.Printf("\033[32mProcess %d passing ring %v to Process %d\033[0m\n", p.id, ring, nextProcess.id)
-			nextProcess.receiveRing(ring)
+			timestamp := p.vectorClock.Send()
+			shivizLog.Log(p.id-1, timestamp, fmt.Sprintf("Process %d passes ring %v to Process %d", p.id, ring, nextProcess.id))
+			nextProcess.receiveRing(ring, timestamp)
 			return
 		}
 	}
@@ -97,7 +119,8 @@ func (p *Process) sendRingToNextActiveProcess(ring []int) {
 }
 
 // Function to receive the ring and process it
-func (p *Process) receiveRing(ring []int) {
+func (p *Process) receiveRing(ring []int, timestamp []int) {
+	shivizLog.Log(p.id-1, p.vectorClock.Merge(timestamp), fmt.Sprintf("Process %d receives ring %v", p.id, ring))
 	// Check if the current process's ID is already in the ring
 	for _, id := range ring {
 		if id == p.id {
@@ -124,6 +147,13 @@ func (p *Process) updateRing(newRing []int) {
 			process.ring = modifiedRing
 			process.lock.Unlock()
 			fmt.Printf("\033[32mProcess %d updated with new ring structure: %v\033[0m\n", process.id, modifiedRing)
+			if process == p {
+				shivizLog.Log(p.id-1, p.vectorClock.Tick(), fmt.Sprintf("Process %d updates its ring to %v", p.id, modifiedRing))
+			} else {
+				timestamp := p.vectorClock.Send()
+				shivizLog.Log(p.id-1, timestamp, fmt.Sprintf("Process %d sends ring %v to Process %d", p.id, modifiedRing, process.id))
+				shivizLog.Log(process.id-1, process.vectorClock.Merge(timestamp), fmt.Sprintf("Process %d updates its ring to %v", process.id, modifiedRing))
+			}
 		}
 	}
 
@@ -139,6 +169,7 @@ func (p *Process) updateRing(newRing []int) {
 		newCoordinator.elected = true
 		coordinator = newCoordinator
 		fmt.Printf("\033[34mProcess %d is elected as the new Coordinator.\033[0m\n", newCoordinator.id)
+		shivizLog.Log(p.id-1, p.vectorClock.Tick(), fmt.Sprintf("Process %d elects Process %d as Coordinator", p.id, newCoordinator.id))
 	}
 	electionInProgress = false
 }
@@ -149,6 +180,7 @@ func (p *Process) updateData(newData int) {
 	defer p.lock.Unlock()
 	p.data = newData
 	fmt.Printf("\033[33mProcess %d changed its data to: %d\033[0m\n", p.id, newData)
+	shivizLog.Log(p.id-1, p.vectorClock.Tick(), fmt.Sprintf("Process %d changes its data to %d", p.id, newData))
 }
 
 // Function to find a process by its ID
@@ -179,6 +211,7 @@ func crashProcess(id int) {
 			proc.lock.Lock()
 			proc.status = 0
 			proc.lock.Unlock()
+			shivizLog.Log(proc.id-1, proc.vectorClock.Tick(), fmt.Sprintf("Process %d crashes", id))
 			fmt.Printf("\033[31mProcess %d crashed (This process leaves silently, its not annoucement. Just for us to know when a process has crashed.).\033[0m\n", id)
 			break
 		}
@@ -188,7 +221,7 @@ func crashProcess(id int) {
 // Function to randomly change data for non-coordinator active processes
 func randomlyChangeData() {
 	for {
-		time.Sleep(time.Duration(rand.Intn(10)+5) * time.Second)
+		time.Sleep(time.Duration(random.Intn("data interval", 10)+5) * time.Second)
 		activeProcesses := getActiveProcesses()
 
 		// Exclude the coordinator from the selection
@@ -198,14 +231,14 @@ func randomlyChangeData() {
 
 		var targetProcess *Process
 		for {
-			targetProcess = activeProcesses[rand.Intn(len(activeProcesses))]
+			targetProcess = activeProcesses[random.Intn("data process", len(activeProcesses))]
 			if targetProcess != coordinator {
 				break
 			}
 		}
 
 		// Update the data of the selected process
-		newData := rand.Intn(100)
+		newData := random.Intn("data", 100)
 		fmt.Printf("\033[33mChanging data for Process %d to: %d\033[0m\n", targetProcess.id, newData)
 		targetProcess.updateData(newData)
 	}
@@ -221,13 +254,43 @@ func allProcessesCrashed() bool {
 }
 
 func main() {
-	rand.Seed(time.Now().UnixNano())
+	flag.StringVar(&SHIVIZ_FILE, "shiviz", "", "write a log for the ShiViz visualiser to this file")
+	flag.Int64Var(&SEED, "seed", 0, "seed of every random decision, taken from the clock when 0")
+	record := flag.String("record", "", "record every random decision to this JSON Lines file")
+	replay := flag.String("replay", "", "repeat the random decisions recorded in this file, as long as the run asks for the same ones")
 	var numProcesses int
-	fmt.Print("\033[38;5;88mEnter the number of processes: \033[0m")
-	fmt.Scanln(&numProcesses)
+	flag.IntVar(&numProcesses, "processes", 0, "number of processes, asked for when not given")
+	flag.DurationVar(&HEARTBEAT_INTERVAL, "heartbeat", HEARTBEAT_INTERVAL, "time between two rounds of the coordinator sending its data and the others checking on it")
+	flag.StringVar(&CRASHES, "crashes", CRASHES, faults.Usage)
+	configFile := flag.String("config", "", config.Usage)
+	flag.Parse()
+	if err := config.Load(flag.CommandLine, *configFile); err != nil {
+		fmt.Println(err)
+		os.Exit(2)
+	}
+	crashes, err := faults.Parse(CRASHES)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(2)
+	}
+	// Every random decision comes from one source, so that a run can be repeated from its seed or its recording
+	random, err = chance.Open(SEED, *record, *replay)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(2)
+	}
+	SEED = random.Seed()
+	if numProcesses == 0 {
+		fmt.Print("\033[38;5;88mEnter the number of processes: \033[0m")
+		fmt.Scanln(&numProcesses)
+	}
+	if numProcesses < 1 {
+		fmt.Println("You need at least 1 process.")
+		os.Exit(2)
+	}
 	// Initialize processes and create the ring structure
 	for i := 1; i <= numProcesses; i++ {
-		processes = append(processes, &Process{id: i, status: 1, data: rand.Intn(100)})
+		processes = append(processes, &Process{id: i, status: 1, data: random.Intn("data", 100), vectorClock: clock.NewVectorClock(i-1, numProcesses)})
 	}
 
 	// Link the process IDs in the ring
@@ -238,6 +301,18 @@ func main() {
 		}
 	}
 
+	if SHIVIZ_FILE != "" {
+		hosts := make([]string, numProcesses)
+		for i := range hosts {
+			hosts[i] = fmt.Sprintf("Process%d", i+1)
+		}
+		shivizLog, err = shiviz.Create(SHIVIZ_FILE, hosts)
+		if err != nil {
+			fmt.Printf("Could not create ShiViz log: %v\n", err)
+		}
+		shivizLog.CloseOnInterrupt()
+	}
+
 	// Set the initial coordinator to the process with the highest ID
 	coordinator = processes[0]
 	for _, proc := range processes {
@@ -253,17 +328,22 @@ func main() {
 	}
 	go randomlyChangeData()
 
-	// Randomly crash and activate processes
-	go func() {
-		for {
-			if allProcessesCrashed() {
-				fmt.Println("\033[31mAll processes have ended. Terminating program.\033[0m")
-				os.Exit(0)
+	// By default a random process crashes every 20 seconds
+	faults.Run(crashes, sim.RealTime(), func(c faults.Crash) {
+		active := []int{}
+		for _, proc := range processes {
+			if proc.status == 1 {
+				active = append(active, proc.id)
+			}
+		}
+		crashProcess(c.Choose(numProcesses, active, coordinator.id, random.For("crash").Intn))
+		if allProcessesCrashed() {
+			fmt.Println("\033[31mAll processes have ended. Terminating program.\033[0m")
+			if err := shivizLog.Close(); err != nil {
+				fmt.Printf("Could not write ShiViz log: %v\n", err)
 			}
-			time.Sleep(10 * time.Second)
-			crashProcess(rand.Intn(numProcesses) + 1) // Crash a random process
-			time.Sleep(10 * time.Second)
+			os.Exit(0)
 		}
-	}()
+	}, allProcessesCrashed)
 	wg.Wait()
 }
@@ -0,0 +1,224 @@
+// Package chance makes every random decision of a run from one seeded
+// source. Each decision can be recorded to a JSON Lines file, and a recorded
+// file can be replayed to repeat the decisions of a failing run exactly.
+//
+// The first line of a recording holds the seed, every other line one
+// decision:
+//
+//	{"seed":7}
+//	{"seq":1,"what":"drop","kind":"float64","value":0.9405090880450124}
+//	{"seq":2,"what":"crash","kind":"intn","n":5,"value":3}
+package chance
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"sync"
+	"time"
+)
+
+// Decision is a single random decision. What names the decision, such as
+// "drop" or "crash", Kind the method that made it and N the bound of Intn.
+type Decision struct {
+	Seq   int     `json:"seq"`
+	What  string  `json:"what"`
+	Kind  string  `json:"kind"`
+	N     int     `json:"n,omitempty"`
+	Value float64 `json:"value"`
+}
+
+// Kinds of decisions
+const (
+	kindIntn        = "intn"
+	kindFloat64     = "float64"
+	kindNormFloat64 = "normfloat64"
+	kindExpFloat64  = "expfloat64"
+)
+
+// Source makes random decisions. It is safe for concurrent use.
+type Source struct {
+	mu       sync.Mutex
+	seed     int64
+	rng      *rand.Rand
+	seq      int
+	record   io.WriteCloser
+	enc      *json.Encoder
+	replay   map[string][]Decision // Recorded decisions still to replay, by what they decide
+	diverged bool
+}
+
+// New returns a source seeded with seed, or with the clock if seed is 0.
+func New(seed int64) *Source {
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	return &Source{seed: seed, rng: rand.New(rand.NewSource(seed))}
+}
+
+// Open returns the source of a run. It replays the decisions recorded at
+// replay unless replay is empty, in which case it is seeded with seed as
+// described for New. Unless record is empty every decision is recorded there.
+func Open(seed int64, record, replay string) (*Source, error) {
+	s := New(seed)
+	if replay != "" {
+		var err error
+		if s, err = Replay(replay); err != nil {
+			return nil, err
+		}
+	}
+	if record != "" {
+		if err := s.Record(record); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// Replay returns a source that repeats the decisions recorded in the file at
+// path. Decisions of the same name are replayed in the order they were
+// recorded. Once the run asks for a decision that was not recorded the source
+// reports the divergence and carries on with the recorded seed.
+func Replay(path string) (*Source, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	var header struct {
+		Seed int64 `json:"seed"`
+	}
+	if !scanner.Scan() {
+		return nil, fmt.Errorf("%s: no seed", path)
+	}
+	if err := json.Unmarshal(scanner.Bytes(), &header); err != nil || header.Seed == 0 {
+		return nil, fmt.Errorf("%s: line 1: no seed", path)
+	}
+	s := New(header.Seed)
+	s.replay = make(map[string][]Decision)
+	for line := 2; scanner.Scan(); line++ {
+		var d Decision
+		if err := json.Unmarshal(scanner.Bytes(), &d); err != nil {
+			return nil, fmt.Errorf("%s: line %d: %w", path, line, err)
+		}
+		s.replay[d.What] = append(s.replay[d.What], d)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return s, nil
+}
+
+// Record creates or truncates the file at path and records the seed and
+// every decision from now on to it. Each decision is written as it is made,
+// so the recording survives a run that crashes or hangs.
+func (s *Source) Record(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.record, s.enc = f, json.NewEncoder(f)
+	return s.enc.Encode(struct {
+		Seed int64 `json:"seed"`
+	}{s.seed})
+}
+
+// Close closes the recording, if any.
+func (s *Source) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.record == nil {
+		return nil
+	}
+	err := s.record.Close()
+	s.record, s.enc = nil, nil
+	return err
+}
+
+// Seed returns the seed of the source.
+func (s *Source) Seed() int64 {
+	return s.seed
+}
+
+// Intn returns a number in [0, n) for the decision named what.
+func (s *Source) Intn(what string, n int) int {
+	return int(s.decide(what, kindIntn, n, func() float64 { return float64(s.rng.Intn(n)) }))
+}
+
+// Float64 returns a number in [0, 1) for the decision named what.
+func (s *Source) Float64(what string) float64 {
+	return s.decide(what, kindFloat64, 0, s.rng.Float64)
+}
+
+// NormFloat64 returns a normally distributed number with mean 0 and standard
+// deviation 1 for the decision named what.
+func (s *Source) NormFloat64(what string) float64 {
+	return s.decide(what, kindNormFloat64, 0, s.rng.NormFloat64)
+}
+
+// ExpFloat64 returns an exponentially distributed number with mean 1 for the
+// decision named what.
+func (s *Source) ExpFloat64(what string) float64 {
+	return s.decide(what, kindExpFloat64, 0, s.rng.ExpFloat64)
+}
+
+func (s *Source) decide(what, kind string, n int, draw func() float64) float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.seq++
+	// The generator draws even when replaying, so that it is where it was in the recorded run if the
+	// replay diverges
+	value := draw()
+	if recorded, ok := s.next(what, kind, n); ok {
+		value = recorded
+	}
+	if s.enc != nil {
+		if err := s.enc.Encode(Decision{s.seq, what, kind, n, value}); err != nil {
+			fmt.Fprintf(os.Stderr, "Could not record random decision %d: %v\n", s.seq, err)
+		}
+	}
+	return value
+}
+
+// Next recorded decision named what, if the source is replaying and the run
+// has not diverged from the recording
+func (s *Source) next(what, kind string, n int) (float64, bool) {
+	if s.replay == nil || s.diverged {
+		return 0, false
+	}
+	queue := s.replay[what]
+	if len(queue) == 0 || queue[0].Kind != kind || queue[0].N != n {
+		s.diverged = true
+		recorded := "nothing"
+		if len(queue) > 0 {
+			recorded = fmt.Sprintf("%s(%d)", queue[0].Kind, queue[0].N)
+		}
+		fmt.Fprintf(os.Stderr, "Replay diverged at random decision %d: the run asks for %s %s(%d) but the recording holds %s. The rest of the run uses seed %d.\n", s.seq, what, kind, n, recorded, s.seed)
+		return 0, false
+	}
+	s.replay[what] = queue[1:]
+	return queue[0].Value, true
+}
+
+// For returns the decisions named what as a source of randomness for the
+// packages that draw their own numbers, such as loss and netsim.
+func (s *Source) For(what string) Stream {
+	return Stream{s, what}
+}
+
+// Stream makes the decisions of one name.
+type Stream struct {
+	source *Source
+	what   string
+}
+
+func (r Stream) Intn(n int) int       { return r.source.Intn(r.what, n) }
+func (r Stream) Float64() float64     { return r.source.Float64(r.what) }
+func (r Stream) NormFloat64() float64 { return r.source.NormFloat64(r.what) }
+func (r Stream) ExpFloat64() float64  { return r.source.ExpFloat64(r.what) }
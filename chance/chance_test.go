@@ -0,0 +1,110 @@
+package chance
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Makes a few decisions of every kind, returning them in a fixed order
+func decisions(s *Source) []float64 {
+	return []float64{
+		float64(s.Intn("crash", 5)),
+		s.Float64("drop"),
+		s.For("net").NormFloat64(),
+		s.For("net").ExpFloat64(),
+		float64(s.For("crash").Intn(5)),
+		s.Float64("drop"),
+	}
+}
+
+func TestReplayRepeatsRecordedDecisions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "run.jsonl")
+	recorded, err := Open(7, path, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := decisions(recorded)
+	if err := recorded.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	replayed, err := Open(99, "", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replayed.Seed() != 7 {
+		t.Errorf("Seed() = %d, want the recorded seed 7", replayed.Seed())
+	}
+	for i, got := range decisions(replayed) {
+		if got != want[i] {
+			t.Errorf("decision %d = %v, want the recorded %v", i+1, got, want[i])
+		}
+	}
+}
+
+func TestReplayMatchesDecisionsByName(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "run.jsonl")
+	recording := `{"seed":7}
+{"seq":1,"what":"drop","kind":"float64","value":0.25}
+{"seq":2,"what":"crash","kind":"intn","n":5,"value":3}
+{"seq":3,"what":"drop","kind":"float64","value":0.75}
+`
+	if err := os.WriteFile(path, []byte(recording), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	s, err := Replay(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The run asks for the decisions in a different order than they were recorded in
+	if got := s.Intn("crash", 5); got != 3 {
+		t.Errorf("crash = %d, want 3", got)
+	}
+	if got := s.Float64("drop"); got != 0.25 {
+		t.Errorf("first drop = %v, want 0.25", got)
+	}
+	if got := s.Float64("drop"); got != 0.75 {
+		t.Errorf("second drop = %v, want 0.75", got)
+	}
+}
+
+func TestReplayCarriesOnWithTheSeedOnceItDiverges(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "run.jsonl")
+	recording := `{"seed":7}
+{"seq":1,"what":"crash","kind":"intn","n":5,"value":3}
+{"seq":2,"what":"drop","kind":"float64","value":0.5}
+`
+	if err := os.WriteFile(path, []byte(recording), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	s, err := Replay(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fresh := New(7)
+	// Asking for a bound the recording does not hold diverges, and from then on
+	// the source makes the decisions the seed would have made
+	s.Intn("crash", 4)
+	fresh.Intn("crash", 4)
+	if got, want := s.Float64("drop"), fresh.Float64("drop"); got != want {
+		t.Errorf("drop after diverging = %v, want %v from the seed", got, want)
+	}
+}
+
+func TestReplayRejectsMalformedRecordings(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"empty.jsonl":   "",
+		"noseed.jsonl":  `{"seq":1,"what":"drop","kind":"float64","value":0.5}` + "\n",
+		"garbage.jsonl": "{\"seed\":7}\nnot json\n",
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Replay(path); err == nil {
+			t.Errorf("Replay(%s) succeeded, want an error", name)
+		}
+	}
+}
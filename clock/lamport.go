@@ -0,0 +1,66 @@
+// Package clock provides the Lamport and vector logical clocks used by the
+// Q1 client-server simulations.
+package clock
+
+import "sync"
+
+// LamportClock is a Lamport logical clock. It is safe for concurrent use.
+type LamportClock struct {
+	mu   sync.Mutex
+	time int
+}
+
+// NewLamportClock returns a Lamport clock starting at 0.
+func NewLamportClock() *LamportClock {
+	return &LamportClock{}
+}
+
+// Time returns the current value of the clock.
+func (c *LamportClock) Time() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.time
+}
+
+// Tick records a local event and returns the new clock value.
+func (c *LamportClock) Tick() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.time++
+	return c.time
+}
+
+// Send records a send event and returns the timestamp to attach to the message.
+func (c *LamportClock) Send() int {
+	return c.Tick()
+}
+
+// Receive merges the timestamp of a received message into the clock
+// (max of both, plus one) and returns the new clock value.
+func (c *LamportClock) Receive(timestamp int) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if timestamp > c.time {
+		c.time = timestamp
+	}
+	c.time++
+	return c.time
+}
+
+// Compare compares the clock with a timestamp, returning -1, 0 or +1 when the
+// clock is smaller than, equal to or greater than the timestamp.
+func (c *LamportClock) Compare(timestamp int) int {
+	t := c.Time()
+	switch {
+	case t < timestamp:
+		return -1
+	case t > timestamp:
+		return 1
+	}
+	return 0
+}
+
+// Copy returns an independent clock with the same value.
+func (c *LamportClock) Copy() *LamportClock {
+	return &LamportClock{time: c.Time()}
+}
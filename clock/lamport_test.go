@@ -0,0 +1,52 @@
+package clock
+
+import "testing"
+
+func TestLamportClockReceive(t *testing.T) {
+	tests := []struct {
+		name      string
+		start     int
+		timestamp int
+		want      int
+	}{
+		{"later timestamp", 2, 7, 8},
+		{"earlier timestamp", 5, 3, 6},
+		{"equal timestamp", 4, 4, 5},
+		{"zero clock and timestamp", 0, 0, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &LamportClock{time: tt.start}
+			if got := c.Receive(tt.timestamp); got != tt.want {
+				t.Errorf("Receive(%d) from %d = %d, want %d", tt.timestamp, tt.start, got, tt.want)
+			}
+			if got := c.Time(); got != tt.want {
+				t.Errorf("Time() after Receive = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLamportClockCompare(t *testing.T) {
+	c := NewLamportClock()
+	c.Send()
+	c.Send()
+	for timestamp, want := range map[int]int{1: 1, 2: 0, 3: -1} {
+		if got := c.Compare(timestamp); got != want {
+			t.Errorf("Compare(%d) at time 2 = %d, want %d", timestamp, got, want)
+		}
+	}
+}
+
+func TestLamportClockCopy(t *testing.T) {
+	c := NewLamportClock()
+	c.Tick()
+	copied := c.Copy()
+	copied.Receive(10)
+	if got := c.Time(); got != 1 {
+		t.Errorf("original = %d after changing the copy, want 1", got)
+	}
+	if got := copied.Time(); got != 11 {
+		t.Errorf("copy = %d, want 11", got)
+	}
+}
@@ -0,0 +1,129 @@
+package clock
+
+import (
+	"fmt"
+	"sync"
+)
+
+// VectorClock is the vector clock of process id in a system of n processes.
+// It is safe for concurrent use. Timestamps handed out by the clock are
+// copies, so they can be attached to messages without further copying.
+type VectorClock struct {
+	mu   sync.Mutex
+	id   int
+	time []int
+}
+
+// NewVectorClock returns a zeroed vector clock for process id out of n.
+func NewVectorClock(id, n int) *VectorClock {
+	return &VectorClock{id: id, time: make([]int, n)}
+}
+
+// ID returns the index of the process owning the clock.
+func (c *VectorClock) ID() int {
+	return c.id
+}
+
+// Time returns a copy of the current timestamp.
+func (c *VectorClock) Time() []int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return copyOf(c.time)
+}
+
+// Tick records a local event and returns the new timestamp.
+func (c *VectorClock) Tick() []int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.time[c.id]++
+	return copyOf(c.time)
+}
+
+// Send records a send event and returns the timestamp to attach to the message.
+func (c *VectorClock) Send() []int {
+	return c.Tick()
+}
+
+// Merge records the receipt of a message stamped with timestamp: every entry
+// becomes the maximum of both vectors and the owner's entry is incremented.
+func (c *VectorClock) Merge(timestamp []int) []int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i := range c.time {
+		if i < len(timestamp) && timestamp[i] > c.time[i] {
+			c.time[i] = timestamp[i]
+		}
+	}
+	c.time[c.id]++
+	return copyOf(c.time)
+}
+
+// Compare reports how the clock relates to timestamp under happened-before.
+func (c *VectorClock) Compare(timestamp []int) Ordering {
+	return Compare(c.Time(), timestamp)
+}
+
+// Copy returns an independent clock with the same owner and value.
+func (c *VectorClock) Copy() *VectorClock {
+	return &VectorClock{id: c.id, time: c.Time()}
+}
+
+// Ordering is the causal relation between two vector timestamps.
+type Ordering int
+
+const (
+	Equal      Ordering = iota // both timestamps are identical
+	Before                     // the first timestamp happened before the second
+	After                      // the second timestamp happened before the first
+	Concurrent                 // neither timestamp happened before the other
+)
+
+func (o Ordering) String() string {
+	switch o {
+	case Equal:
+		return "Equal"
+	case Before:
+		return "Before"
+	case After:
+		return "After"
+	case Concurrent:
+		return "Concurrent"
+	}
+	return fmt.Sprintf("Ordering(%d)", int(o))
+}
+
+// Compare reports how x relates to y: Before if x is less than or equal to y
+// in every entry and strictly less in at least one, After in the symmetric
+// case, Equal if all entries match and Concurrent otherwise. Missing entries
+// of the shorter timestamp count as zero.
+func Compare(x, y []int) Ordering {
+	less, greater := false, false
+	for i := 0; i < len(x) || i < len(y); i++ {
+		a, b := entry(x, i), entry(y, i)
+		if a < b {
+			less = true
+		} else if a > b {
+			greater = true
+		}
+	}
+	switch {
+	case less && greater:
+		return Concurrent
+	case less:
+		return Before
+	case greater:
+		return After
+	}
+	return Equal
+}
+
+func entry(v []int, i int) int {
+	if i < len(v) {
+		return v[i]
+	}
+	return 0
+}
+
+func copyOf(v []int) []int {
+	return append([]int(nil), v...)
+}
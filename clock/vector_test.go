@@ -0,0 +1,89 @@
+package clock
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		name string
+		x, y []int
+		want Ordering
+	}{
+		{"equal", []int{1, 2, 3}, []int{1, 2, 3}, Equal},
+		{"both empty", nil, nil, Equal},
+		{"before", []int{1, 2, 3}, []int{1, 3, 3}, Before},
+		{"before in every entry", []int{0, 0}, []int{1, 1}, Before},
+		{"after", []int{2, 2, 3}, []int{1, 2, 3}, After},
+		{"concurrent", []int{2, 1}, []int{1, 2}, Concurrent},
+		{"shorter equal to zero padding", []int{1, 2}, []int{1, 2, 0}, Equal},
+		{"shorter before", []int{1, 2}, []int{1, 2, 1}, Before},
+		{"longer after", []int{1, 2, 1}, []int{1, 2}, After},
+		{"different lengths concurrent", []int{3}, []int{1, 1}, Concurrent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Compare(tt.x, tt.y); got != tt.want {
+				t.Errorf("Compare(%v, %v) = %v, want %v", tt.x, tt.y, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVectorClockMerge(t *testing.T) {
+	tests := []struct {
+		name      string
+		start     []int
+		timestamp []int
+		want      []int
+	}{
+		{"takes the maximum and ticks its own entry", []int{1, 0, 4}, []int{0, 3, 2}, []int{1, 4, 4}},
+		{"own entry ticks past a larger one", []int{1, 0, 0}, []int{0, 5, 0}, []int{1, 6, 0}},
+		{"shorter timestamp", []int{0, 1, 2}, []int{3}, []int{3, 2, 2}},
+		{"longer timestamp is cut to the clock", []int{0, 0}, []int{1, 1, 7}, []int{1, 2}},
+		{"nil timestamp only ticks", []int{2, 2}, nil, []int{2, 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &VectorClock{id: 1, time: slices.Clone(tt.start)}
+			if got := c.Merge(tt.timestamp); !slices.Equal(got, tt.want) {
+				t.Errorf("Merge(%v) from %v = %v, want %v", tt.timestamp, tt.start, got, tt.want)
+			}
+			if got := c.Time(); !slices.Equal(got, tt.want) {
+				t.Errorf("Time() after Merge = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVectorClockTimestampsDoNotAlias(t *testing.T) {
+	c := NewVectorClock(0, 2)
+	sent := c.Send()
+	sent[1] = 99
+	if got := c.Time(); !slices.Equal(got, []int{1, 0}) {
+		t.Errorf("changing a sent timestamp changed the clock to %v", got)
+	}
+	now := c.Time()
+	c.Tick()
+	if !slices.Equal(now, []int{1, 0}) {
+		t.Errorf("ticking changed an earlier Time() to %v", now)
+	}
+}
+
+func TestVectorClockCopy(t *testing.T) {
+	c := NewVectorClock(1, 3)
+	c.Tick()
+	copied := c.Copy()
+	c.Tick()
+	copied.Merge([]int{5, 0, 0})
+	if got := c.Time(); !slices.Equal(got, []int{0, 2, 0}) {
+		t.Errorf("original = %v after changing the copy, want [0 2 0]", got)
+	}
+	if got := copied.Time(); !slices.Equal(got, []int{5, 2, 0}) {
+		t.Errorf("copy = %v, want [5 2 0]", got)
+	}
+	if copied.ID() != c.ID() {
+		t.Errorf("copy has ID %d, want %d", copied.ID(), c.ID())
+	}
+}
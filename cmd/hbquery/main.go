@@ -0,0 +1,112 @@
+// Command hbquery rebuilds the happened-before graph of a trace recorded by
+// Q1_3 with -trace and answers questions about it. Events are referred to by
+// their line number in the trace, as printed by the list command.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/Jashveragiwala/Lamport-and-Vector-Clocks/trace"
+)
+
+const usage = `Usage: hbquery -trace <file> <command>
+
+Commands:
+  list               list every event with its number
+  precedes <a> <b>   tell whether event a happened before event b
+  concurrent <x>     list the events concurrent with event x
+  past <x>           list the causal past of event x
+  future <x>         list the events that event x happened before
+  verify             check the vector timestamps against the graph
+`
+
+func main() {
+	traceFile := flag.String("trace", "", "JSON Lines trace written by Q1_3 -trace")
+	flag.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	flag.Parse()
+	args := flag.Args()
+	if *traceFile == "" || len(args) == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	events, err := trace.ReadFile(*traceFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Could not read trace: %v\n", err)
+		os.Exit(1)
+	}
+	graph := trace.NewGraph(events)
+
+	switch args[0] {
+	case "list":
+		printEvents(graph, allEvents(len(events)))
+	case "precedes":
+		a, b := eventArg(graph, args, 1), eventArg(graph, args, 2)
+		switch {
+		case graph.HappenedBefore(a, b):
+			fmt.Printf("Yes: #%d happened before #%d.\n", a+1, b+1)
+		case graph.HappenedBefore(b, a):
+			fmt.Printf("No: #%d happened before #%d.\n", b+1, a+1)
+		default:
+			fmt.Printf("No: #%d and #%d are concurrent.\n", a+1, b+1)
+		}
+	case "concurrent":
+		x := eventArg(graph, args, 1)
+		concurrent := graph.Concurrent(x)
+		fmt.Printf("%d events are concurrent with #%d %s:\n", len(concurrent), x+1, events[x])
+		printEvents(graph, concurrent)
+	case "past":
+		x := eventArg(graph, args, 1)
+		past := graph.Past(x)
+		fmt.Printf("The causal past of #%d %s has %d events:\n", x+1, events[x], len(past))
+		printEvents(graph, past)
+	case "future":
+		x := eventArg(graph, args, 1)
+		future := graph.Future(x)
+		fmt.Printf("#%d %s happened before %d events:\n", x+1, events[x], len(future))
+		printEvents(graph, future)
+	case "verify":
+		problems := graph.Verify()
+		for _, problem := range problems {
+			fmt.Println(problem)
+		}
+		if len(problems) > 0 {
+			os.Exit(1)
+		}
+		fmt.Printf("The vector timestamps of all %d events agree with the happened-before graph.\n", len(events))
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+}
+
+// Parses the event number at position i of the arguments
+func eventArg(graph *trace.Graph, args []string, i int) int {
+	if i >= len(args) {
+		flag.Usage()
+		os.Exit(2)
+	}
+	n, err := strconv.Atoi(args[i])
+	if err != nil || n < 1 || n > len(graph.Events) {
+		fmt.Fprintf(os.Stderr, "Event %q does not exist, the trace has events 1 to %d.\n", args[i], len(graph.Events))
+		os.Exit(2)
+	}
+	return n - 1
+}
+
+func allEvents(n int) []int {
+	all := make([]int, n)
+	for i := range all {
+		all[i] = i
+	}
+	return all
+}
+
+func printEvents(graph *trace.Graph, indices []int) {
+	for _, i := range indices {
+		fmt.Printf("#%-4d %-55s %v\n", i+1, graph.Events[i], graph.Events[i].Vector)
+	}
+}
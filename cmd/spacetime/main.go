@@ -0,0 +1,49 @@
+// Command spacetime renders a trace recorded by Q1_3 with -trace as an SVG
+// space-time diagram: one line per process, an arrow per delivered message,
+// dropped messages and causality violations in red, and every event labelled
+// with its vector timestamp or a Lamport timestamp derived from the
+// happened-before graph.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/Jashveragiwala/Lamport-and-Vector-Clocks/trace"
+)
+
+func main() {
+	traceFile := flag.String("trace", "", "JSON Lines trace written by Q1_3 -trace")
+	output := flag.String("o", "spacetime.svg", "SVG file to write")
+	label := flag.String("label", "vector", "timestamps to label events with: vector or lamport")
+	flag.Parse()
+	if *traceFile == "" || (*label != "vector" && *label != "lamport") {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	events, err := trace.ReadFile(*traceFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Could not read trace: %v\n", err)
+		os.Exit(1)
+	}
+
+	f, err := os.Create(*output)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Could not create diagram: %v\n", err)
+		os.Exit(1)
+	}
+	w := bufio.NewWriter(f)
+	render(w, *traceFile, trace.NewGraph(events), *label == "lamport")
+	if err := w.Flush(); err != nil {
+		fmt.Fprintf(os.Stderr, "Could not write diagram: %v\n", err)
+		os.Exit(1)
+	}
+	if err := f.Close(); err != nil {
+		fmt.Fprintf(os.Stderr, "Could not write diagram: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Space-time diagram of %d events written to %s.\n", len(events), *output)
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"sort"
+	"strconv"
+
+	"github.com/Jashveragiwala/Lamport-and-Vector-Clocks/trace"
+)
+
+const (
+	marginLeft   = 90
+	marginTop    = 60
+	rowGap       = 110
+	minStep      = 50
+	charWidth    = 7
+	eventRadius  = 4
+	legendHeight = 70
+)
+
+// Renders the graph as an SVG space-time diagram, time running from left to
+// right. Events are placed by their Lamport timestamp, so every arrow points
+// forward in time.
+func render(w io.Writer, title string, graph *trace.Graph, lamportLabels bool) {
+	events := graph.Events
+	lamport := graph.Lamport()
+
+	labels := make([]string, len(events))
+	step, last := minStep, 0
+	for i, e := range events {
+		if lamportLabels {
+			labels[i] = strconv.Itoa(lamport[i])
+		} else {
+			labels[i] = fmt.Sprint(e.Vector)
+		}
+		step = max(step, len(labels[i])*charWidth+10)
+		last = max(last, lamport[i])
+	}
+
+	// One row per process, the server first
+	rows := make(map[int]int)
+	var processes []int
+	for _, e := range events {
+		if _, ok := rows[e.Process]; !ok {
+			rows[e.Process] = 0
+			processes = append(processes, e.Process)
+		}
+	}
+	sort.Ints(processes)
+	for i, process := range processes {
+		rows[process] = i
+	}
+
+	x := func(i int) int { return marginLeft + lamport[i]*step }
+	y := func(i int) int { return marginTop + rows[events[i].Process]*rowGap }
+	width := marginLeft + (last+1)*step
+	height := marginTop + len(processes)*rowGap + legendHeight
+
+	fmt.Fprintf(w, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" font-family="monospace" font-size="11">`+"\n", width, height)
+	fmt.Fprintln(w, `<defs>`)
+	for _, color := range []string{"steelblue", "crimson"} {
+		fmt.Fprintf(w, `<marker id="arrow-%s" viewBox="0 0 10 10" refX="10" refY="5" markerWidth="7" markerHeight="7" orient="auto"><path d="M0,0 L10,5 L0,10 z" fill="%s"/></marker>`+"\n", color, color)
+	}
+	fmt.Fprintln(w, `</defs>`)
+	fmt.Fprintln(w, `<rect width="100%" height="100%" fill="white"/>`)
+	fmt.Fprintf(w, `<text x="10" y="20" font-size="14">%s</text>`+"\n", html.EscapeString(title))
+
+	// Process lines
+	for i, process := range processes {
+		name := "Server"
+		if process != 0 {
+			name = fmt.Sprintf("Client %d", process)
+		}
+		rowY := marginTop + i*rowGap
+		fmt.Fprintf(w, `<text x="10" y="%d" font-size="13">%s</text>`+"\n", rowY+4, name)
+		fmt.Fprintf(w, `<line x1="%d" y1="%d" x2="%d" y2="%d" stroke="black"/>`+"\n", marginLeft-10, rowY, width-10, rowY)
+	}
+
+	// Message arrows, red when the receive was a causality violation
+	for _, message := range graph.Messages() {
+		send, receive := message[0], message[1]
+		color := "steelblue"
+		if events[receive].Violation {
+			color = "crimson"
+		}
+		fmt.Fprintf(w, `<line x1="%d" y1="%d" x2="%d" y2="%d" stroke="%s" marker-end="url(#arrow-%s)"><title>%s</title></line>`+"\n",
+			x(send), y(send), x(receive), y(receive)-eventRadius*sign(y(receive)-y(send)), color, color, html.EscapeString(events[send].String()))
+	}
+
+	// Events and their timestamps. Broadcasts of one message share a position, and so may a drop of it for
+	// some clients, so drops are marked after every other event and only events of the same kind are merged
+	order := make([]int, len(events))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return events[order[a]].Type != trace.ServerDrop && events[order[b]].Type == trace.ServerDrop
+	})
+	dotted, crossed, labelled := make(map[[2]int]bool), make(map[[2]int]bool), make(map[[2]int]bool)
+	for _, i := range order {
+		e := events[i]
+		position := [2]int{x(i), y(i)}
+		description := html.EscapeString(e.String())
+		switch {
+		case e.Type == trace.ServerDrop:
+			if crossed[position] {
+				continue
+			}
+			crossed[position] = true
+			d := eventRadius + 2
+			fmt.Fprintf(w, `<g stroke="crimson" stroke-width="2"><title>%s</title><line x1="%d" y1="%d" x2="%d" y2="%d"/><line x1="%d" y1="%d" x2="%d" y2="%d"/></g>`+"\n",
+				description, x(i)-d, y(i)-d, x(i)+d, y(i)+d, x(i)-d, y(i)+d, x(i)+d, y(i)-d)
+		case dotted[position]:
+			continue
+		case e.Violation:
+			dotted[position] = true
+			fmt.Fprintf(w, `<circle cx="%d" cy="%d" r="%d" fill="crimson"><title>%s (causality violation)</title></circle>`+"\n", x(i), y(i), eventRadius+1, description)
+		default:
+			dotted[position] = true
+			fmt.Fprintf(w, `<circle cx="%d" cy="%d" r="%d" fill="black"><title>%s</title></circle>`+"\n", x(i), y(i), eventRadius, description)
+		}
+		if !labelled[position] {
+			labelled[position] = true
+			fmt.Fprintf(w, `<text x="%d" y="%d" text-anchor="middle">%s</text>`+"\n", x(i), y(i)-10, labels[i])
+		}
+	}
+
+	// Legend
+	legendY := marginTop + len(processes)*rowGap
+	fmt.Fprintf(w, `<line x1="10" y1="%d" x2="40" y2="%d" stroke="steelblue" marker-end="url(#arrow-steelblue)"/><text x="50" y="%d">message</text>`+"\n", legendY, legendY, legendY+4)
+	fmt.Fprintf(w, `<line x1="140" y1="%d" x2="170" y2="%d" stroke="crimson" marker-end="url(#arrow-crimson)"/><text x="180" y="%d">causality violation</text>`+"\n", legendY, legendY, legendY+4)
+	fmt.Fprintf(w, `<g stroke="crimson" stroke-width="2"><line x1="334" y1="%d" x2="346" y2="%d"/><line x1="334" y1="%d" x2="346" y2="%d"/></g><text x="355" y="%d">dropped message</text>`+"\n", legendY-6, legendY+6, legendY+6, legendY-6, legendY+4)
+	fmt.Fprintln(w, `</svg>`)
+}
+
+func sign(v int) int {
+	switch {
+	case v > 0:
+		return 1
+	case v < 0:
+		return -1
+	}
+	return 0
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/Jashveragiwala/Lamport-and-Vector-Clocks/trace"
+)
+
+// The server broadcasts Client 1's message to Client 2 and drops it for Client 3, so the broadcast and the drop
+// share a position on the server's line
+func collidingTrace() []trace.Event {
+	return []trace.Event{
+		{Process: 1, Peer: 0, Sender: 1, MessageID: 1, Vector: []int{0, 1, 0, 0}, Type: trace.ClientSend},
+		{Process: 0, Peer: 1, Sender: 1, MessageID: 1, Vector: []int{1, 1, 0, 0}, Type: trace.ServerReceive},
+		{Process: 0, Peer: 2, Sender: 1, MessageID: 1, Vector: []int{2, 1, 0, 0}, Type: trace.ServerBroadcast},
+		{Process: 0, Peer: 3, Sender: 1, MessageID: 1, Vector: []int{2, 1, 0, 0}, Type: trace.ServerDrop},
+		{Process: 2, Peer: 0, Sender: 1, MessageID: 1, Vector: []int{2, 1, 1, 0}, Type: trace.ClientReceive, Violation: true},
+		{Process: 3, Peer: 0, Sender: 3, MessageID: 1, Vector: []int{0, 0, 0, 1}, Type: trace.ClientSend},
+	}
+}
+
+func TestRenderDrawsEveryEventOnce(t *testing.T) {
+	var buf bytes.Buffer
+	render(&buf, "a <run>", trace.NewGraph(collidingTrace()), false)
+	svg := buf.String()
+
+	// The diagram must be well formed, title included
+	dec := xml.NewDecoder(strings.NewReader(svg))
+	for {
+		if _, err := dec.Token(); err != nil {
+			if err != io.EOF {
+				t.Fatalf("invalid SVG: %v", err)
+			}
+			break
+		}
+	}
+
+	tests := []struct {
+		what, marker string
+		want         int
+	}{
+		// Client 1's send, the server's receive and shared broadcast, and both clients' events
+		{"event dots", `<circle `, 5},
+		{"violation dots", `fill="crimson"><title>`, 1},
+		{"drop crosses", `<g stroke="crimson" stroke-width="2"><title>`, 1},
+		{"message arrows", `marker-end="url(#arrow-`, 2 + 2}, // two messages plus the legend
+		{"violation arrows", `stroke="crimson" marker-end`, 1 + 1},
+		{"timestamp labels", `text-anchor="middle">`, 5},
+	}
+	for _, tt := range tests {
+		if got := strings.Count(svg, tt.marker); got != tt.want {
+			t.Errorf("%d %s, want %d", got, tt.what, tt.want)
+		}
+	}
+	if !strings.Contains(svg, "Server drops Message 1 from Client 1") {
+		t.Errorf("drop cross has no description")
+	}
+	if !strings.Contains(svg, "a &lt;run&gt;") {
+		t.Errorf("title is not escaped")
+	}
+}
+
+func TestRenderLamportLabels(t *testing.T) {
+	var buf bytes.Buffer
+	render(&buf, "run", trace.NewGraph(collidingTrace()), true)
+	for _, label := range []string{">1</text>", ">2</text>", ">3</text>", ">4</text>"} {
+		if !strings.Contains(buf.String(), label) {
+			t.Errorf("no Lamport label %s", label)
+		}
+	}
+	if strings.Contains(buf.String(), "[2 1 0 0]") {
+		t.Errorf("vector timestamps drawn with Lamport labels")
+	}
+}
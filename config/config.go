@@ -0,0 +1,73 @@
+// Package config fills in command-line flags from a JSON configuration file,
+// so that a run can be described once and repeated without any prompts.
+//
+// The file holds one object whose keys are flag names:
+//
+//	{"clients": 4, "messages": 100, "delay": "10ms", "drop": 0.2, "seed": 7}
+//
+// Flags given on the command line win over the file. Only JSON is read, YAML
+// and other formats are not supported.
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+)
+
+// Usage describes the flag that names the configuration file.
+const Usage = "read flags from this JSON file, an object keyed by flag name; flags on the command line win"
+
+// Load sets every flag of fs named in the JSON file at path that was not set
+// on the command line. An empty path loads nothing.
+func Load(fs *flag.FlagSet, path string) error {
+	if path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var settings map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&settings); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	given := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { given[f.Name] = true })
+	names := make([]string, 0, len(settings))
+	for name := range settings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		value := settings[name]
+		if fs.Lookup(name) == nil {
+			return fmt.Errorf("%s: unknown setting %q", path, name)
+		}
+		if given[name] {
+			continue
+		}
+		var s string
+		switch v := value.(type) {
+		case string:
+			s = v
+		case json.Number:
+			s = v.String()
+		case bool:
+			s = strconv.FormatBool(v)
+		default:
+			return fmt.Errorf("%s: setting %q must be a string, number or boolean", path, name)
+		}
+		if err := fs.Set(name, s); err != nil {
+			return fmt.Errorf("%s: setting %q: %w", path, name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package config
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Flags as the programs define them
+type flags struct {
+	fs       *flag.FlagSet
+	clients  *int
+	drop     *string
+	delay    *time.Duration
+	causal   *bool
+	seed     *int64
+	messages *int
+}
+
+func newFlags() flags {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	return flags{
+		fs:       fs,
+		clients:  fs.Int("clients", 0, ""),
+		drop:     fs.String("drop", "fixed:0.5", ""),
+		delay:    fs.Duration("delay", time.Second, ""),
+		causal:   fs.Bool("causal", false, ""),
+		seed:     fs.Int64("seed", 0, ""),
+		messages: fs.Int("messages", 0, ""),
+	}
+}
+
+// Writes a configuration file and returns its path
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "run.json")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadSetsFlagsFromTheFile(t *testing.T) {
+	f := newFlags()
+	path := writeConfig(t, `{"clients": 4, "drop": 0.2, "delay": "10ms", "causal": true, "seed": 1792219443766715164, "messages": -1}`)
+	if err := Load(f.fs, path); err != nil {
+		t.Fatal(err)
+	}
+	if *f.clients != 4 || *f.drop != "0.2" || *f.delay != 10*time.Millisecond || !*f.causal || *f.seed != 1792219443766715164 || *f.messages != -1 {
+		t.Errorf("flags are clients=%d drop=%q delay=%v causal=%v seed=%d messages=%d", *f.clients, *f.drop, *f.delay, *f.causal, *f.seed, *f.messages)
+	}
+}
+
+func TestLoadLetsTheCommandLineWin(t *testing.T) {
+	f := newFlags()
+	if err := f.fs.Parse([]string{"-clients", "2", "-drop", "never"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := Load(f.fs, writeConfig(t, `{"clients": 4, "drop": "fixed:0.1", "delay": "5ms"}`)); err != nil {
+		t.Fatal(err)
+	}
+	if *f.clients != 2 || *f.drop != "never" || *f.delay != 5*time.Millisecond {
+		t.Errorf("flags are clients=%d drop=%q delay=%v, want 2, never and 5ms", *f.clients, *f.drop, *f.delay)
+	}
+}
+
+func TestLoadWithoutAFileLeavesTheFlags(t *testing.T) {
+	f := newFlags()
+	if err := Load(f.fs, ""); err != nil {
+		t.Fatal(err)
+	}
+	if *f.clients != 0 || *f.drop != "fixed:0.5" || *f.delay != time.Second {
+		t.Errorf("flags are clients=%d drop=%q delay=%v, want their defaults", *f.clients, *f.drop, *f.delay)
+	}
+}
+
+func TestLoadRejectsBadFiles(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"unknown setting", `{"clients": 4, "processes": 5}`, `unknown setting "processes"`},
+		{"list", `{"clients": [1, 2]}`, `must be a string, number or boolean`},
+		{"object", `{"drop": {"fixed": 0.5}}`, `must be a string, number or boolean`},
+		{"invalid value", `{"clients": "many"}`, `setting "clients"`},
+		{"duration without unit", `{"delay": 10}`, `setting "delay"`},
+		{"not an object", `[{"clients": 4}]`, `run.json`},
+		{"YAML", "clients: 4\ndrop: 0.2\n", `run.json`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Load(newFlags().fs, writeConfig(t, tt.content))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Load = %v, want an error containing %q", err, tt.want)
+			}
+		})
+	}
+	if err := Load(newFlags().fs, filepath.Join(t.TempDir(), "missing.json")); !os.IsNotExist(err) {
+		t.Errorf("Load of a missing file = %v, want a not-exist error", err)
+	}
+}
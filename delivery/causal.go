@@ -0,0 +1,86 @@
+// Package delivery provides hold-back queues that delay the delivery of
+// received broadcasts until an ordering guarantee can be met.
+package delivery
+
+import "sync"
+
+// CausalQueue implements Birman-Schiper-Stephenson causal delivery for
+// process self in a group of n processes. Every broadcast carries a vector
+// whose entry for its sender is the sender's broadcast sequence number and
+// whose other entries count the messages the sender had delivered from each
+// process when it broadcast. It is safe for concurrent use.
+type CausalQueue[T any] struct {
+	mu        sync.Mutex
+	self      int
+	delivered []int
+	pending   []held[T]
+}
+
+type held[T any] struct {
+	sender int
+	vector []int
+	msg    T
+}
+
+// NewCausalQueue returns an empty queue for process self out of n.
+func NewCausalQueue[T any](self, n int) *CausalQueue[T] {
+	return &CausalQueue[T]{self: self, delivered: make([]int, n)}
+}
+
+// Add buffers msg, broadcast by sender with vector, and returns every
+// message that has become deliverable in the order it must be delivered.
+// A message from outside the group, or whose vector does not have one entry
+// for each process, can never be delivered and is discarded.
+func (q *CausalQueue[T]) Add(sender int, vector []int, msg T) []T {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if sender < 0 || sender >= len(q.delivered) || len(vector) != len(q.delivered) {
+		return nil
+	}
+	q.pending = append(q.pending, held[T]{sender, append([]int(nil), vector...), msg})
+
+	var ready []T
+	for progress := true; progress; {
+		progress = false
+		for i, h := range q.pending {
+			if q.deliverable(h) {
+				q.delivered[h.sender] = h.vector[h.sender]
+				ready = append(ready, h.msg)
+				q.pending = append(q.pending[:i], q.pending[i+1:]...)
+				progress = true
+				break
+			}
+		}
+	}
+	return ready
+}
+
+// A message is deliverable once it is the next one from its sender and every
+// message its sender had delivered before broadcasting it has been delivered
+// here too. The queue's own process trivially has all of its own messages.
+func (q *CausalQueue[T]) deliverable(h held[T]) bool {
+	if h.vector[h.sender] != q.delivered[h.sender]+1 {
+		return false
+	}
+	for k, v := range h.vector {
+		if k != h.sender && k != q.self && v > q.delivered[k] {
+			return false
+		}
+	}
+	return true
+}
+
+// Delivered returns a copy of the number of messages delivered from each
+// process, which is the vector to attach to the next broadcast.
+func (q *CausalQueue[T]) Delivered() []int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return append([]int(nil), q.delivered...)
+}
+
+// Len returns the number of messages held back.
+func (q *CausalQueue[T]) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.pending)
+}
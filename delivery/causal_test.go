@@ -0,0 +1,70 @@
+package delivery
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestCausalQueueHoldsBackUntilDependenciesArrive(t *testing.T) {
+	// Process 0 of 3 receives a broadcast of process 2 that depends on the first broadcast of process 1 before that one
+	q := NewCausalQueue[string](0, 3)
+	if got := q.Add(2, []int{0, 1, 1}, "2:1"); len(got) != 0 {
+		t.Fatalf("delivered %v before its dependency", got)
+	}
+	if q.Len() != 1 {
+		t.Fatalf("Len() = %d, want 1", q.Len())
+	}
+	if got := q.Add(1, []int{0, 1, 0}, "1:1"); !slices.Equal(got, []string{"1:1", "2:1"}) {
+		t.Fatalf("delivered %v, want [1:1 2:1]", got)
+	}
+	if got := q.Delivered(); !slices.Equal(got, []int{0, 1, 1}) {
+		t.Errorf("Delivered() = %v, want [0 1 1]", got)
+	}
+	if q.Len() != 0 {
+		t.Errorf("Len() = %d after delivering everything, want 0", q.Len())
+	}
+}
+
+func TestCausalQueueDeliversEachSenderInOrder(t *testing.T) {
+	q := NewCausalQueue[int](0, 2)
+	if got := q.Add(1, []int{0, 3}, 3); len(got) != 0 {
+		t.Fatalf("delivered %v out of order", got)
+	}
+	if got := q.Add(1, []int{0, 2}, 2); len(got) != 0 {
+		t.Fatalf("delivered %v out of order", got)
+	}
+	if got := q.Add(1, []int{0, 1}, 1); !slices.Equal(got, []int{1, 2, 3}) {
+		t.Fatalf("delivered %v, want [1 2 3]", got)
+	}
+}
+
+func TestCausalQueueIgnoresItsOwnEntry(t *testing.T) {
+	// A broadcast that depends on the queue's own process is deliverable, since the process has all of its messages
+	q := NewCausalQueue[string](0, 2)
+	if got := q.Add(1, []int{4, 1}, "1:1"); !slices.Equal(got, []string{"1:1"}) {
+		t.Fatalf("delivered %v, want [1:1]", got)
+	}
+}
+
+func TestCausalQueueDiscardsMalformedVectors(t *testing.T) {
+	q := NewCausalQueue[string](0, 2)
+	for _, tt := range []struct {
+		sender int
+		vector []int
+	}{
+		{1, []int{0, 1, 7}},
+		{1, []int{1}},
+		{2, []int{0, 1}},
+		{-1, []int{0, 1}},
+	} {
+		if got := q.Add(tt.sender, tt.vector, "bad"); len(got) != 0 {
+			t.Errorf("Add(%d, %v) delivered %v", tt.sender, tt.vector, got)
+		}
+	}
+	if q.Len() != 0 {
+		t.Errorf("Len() = %d, want malformed messages discarded", q.Len())
+	}
+	if got := q.Add(1, []int{0, 1}, "1:1"); !slices.Equal(got, []string{"1:1"}) {
+		t.Errorf("delivered %v after malformed messages, want [1:1]", got)
+	}
+}
@@ -0,0 +1,62 @@
+package delivery
+
+import "sync"
+
+// FIFOQueue restores the order of messages sent over links that may reorder
+// or duplicate them. Every sender numbers the messages it sends over its link
+// 1, 2, 3 and so on, and the queue releases them in that order. It is safe for
+// concurrent use.
+type FIFOQueue[T any] struct {
+	mu      sync.Mutex
+	next    map[int]int       // Sequence number of the next message to release from every sender
+	pending map[int]map[int]T // Messages that arrived ahead of their turn, by sender and sequence number
+}
+
+// NewFIFOQueue returns an empty queue.
+func NewFIFOQueue[T any]() *FIFOQueue[T] {
+	return &FIFOQueue[T]{next: make(map[int]int), pending: make(map[int]map[int]T)}
+}
+
+// Add buffers msg, message seq from sender, and returns every message from
+// sender that can now be released in the order it was sent. A message that
+// was already released or is already buffered is a duplicate: it is
+// discarded and Add reports it.
+func (q *FIFOQueue[T]) Add(sender, seq int, msg T) (ready []T, duplicate bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	next, ok := q.next[sender]
+	if !ok {
+		next = 1
+	}
+	held := q.pending[sender]
+	if _, buffered := held[seq]; buffered || seq < next {
+		return nil, true
+	}
+	if held == nil {
+		held = make(map[int]T)
+		q.pending[sender] = held
+	}
+	held[seq] = msg
+	for {
+		msg, ok := held[next]
+		if !ok {
+			break
+		}
+		delete(held, next)
+		ready = append(ready, msg)
+		next++
+	}
+	q.next[sender] = next
+	return ready, false
+}
+
+// Len returns the number of messages waiting for an earlier one.
+func (q *FIFOQueue[T]) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	n := 0
+	for _, held := range q.pending {
+		n += len(held)
+	}
+	return n
+}
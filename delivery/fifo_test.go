@@ -0,0 +1,40 @@
+package delivery
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+)
+
+func TestFIFOQueue(t *testing.T) {
+	type arrival struct {
+		sender, seq int
+		ready       []string
+		duplicate   bool
+	}
+	tests := []struct {
+		name     string
+		arrivals []arrival
+	}{
+		{"in order", []arrival{{1, 1, []string{"1:1"}, false}, {1, 2, []string{"1:2"}, false}}},
+		{"reordered", []arrival{{1, 3, nil, false}, {1, 2, nil, false}, {1, 1, []string{"1:1", "1:2", "1:3"}, false}}},
+		{"duplicate after release", []arrival{{1, 1, []string{"1:1"}, false}, {1, 1, nil, true}, {1, 2, []string{"1:2"}, false}}},
+		{"duplicate while held", []arrival{{1, 2, nil, false}, {1, 2, nil, true}, {1, 1, []string{"1:1", "1:2"}, false}}},
+		{"senders are independent", []arrival{{1, 2, nil, false}, {2, 1, []string{"2:1"}, false}, {1, 1, []string{"1:1", "1:2"}, false}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := NewFIFOQueue[string]()
+			for _, a := range tt.arrivals {
+				msg := fmt.Sprintf("%d:%d", a.sender, a.seq)
+				ready, duplicate := q.Add(a.sender, a.seq, msg)
+				if !slices.Equal(ready, a.ready) || duplicate != a.duplicate {
+					t.Fatalf("Add(%d, %d) = %v, %t, want %v, %t", a.sender, a.seq, ready, duplicate, a.ready, a.duplicate)
+				}
+			}
+			if q.Len() != 0 {
+				t.Errorf("Len() = %d after every gap was filled, want 0", q.Len())
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package delivery
+
+import (
+	"sort"
+	"sync"
+)
+
+// TotalOrderQueue delivers multicast messages in (Lamport timestamp, sender)
+// order. A message is delivered once it is at the head of the queue and every
+// member of the group has acknowledged it. This yields the same delivery
+// order at every member provided each member acknowledges messages only after
+// receiving them and the channels between members are FIFO. It is safe for
+// concurrent use.
+type TotalOrderQueue[T any] struct {
+	mu      sync.Mutex
+	members []int
+	pending []ordered[T]
+	acks    map[Key]map[int]bool
+}
+
+// Key identifies a message by its Lamport timestamp and sender.
+type Key struct {
+	Timestamp int
+	Sender    int
+}
+
+// Less orders keys by timestamp, breaking ties with the sender ID.
+func (k Key) Less(other Key) bool {
+	if k.Timestamp != other.Timestamp {
+		return k.Timestamp < other.Timestamp
+	}
+	return k.Sender < other.Sender
+}
+
+type ordered[T any] struct {
+	key Key
+	msg T
+}
+
+// NewTotalOrderQueue returns an empty queue for a group with the given members.
+func NewTotalOrderQueue[T any](members []int) *TotalOrderQueue[T] {
+	return &TotalOrderQueue[T]{members: append([]int(nil), members...), acks: make(map[Key]map[int]bool)}
+}
+
+// Add queues msg and returns the messages that have become deliverable.
+func (q *TotalOrderQueue[T]) Add(key Key, msg T) []T {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	i := sort.Search(len(q.pending), func(i int) bool { return key.Less(q.pending[i].key) })
+	q.pending = append(q.pending, ordered[T]{})
+	copy(q.pending[i+1:], q.pending[i:])
+	q.pending[i] = ordered[T]{key, msg}
+	return q.ready()
+}
+
+// Ack records that member has acknowledged the message identified by key and
+// returns the messages that have become deliverable. Acknowledgements may
+// arrive before the message itself.
+func (q *TotalOrderQueue[T]) Ack(key Key, member int) []T {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.acks[key] == nil {
+		q.acks[key] = make(map[int]bool)
+	}
+	q.acks[key][member] = true
+	return q.ready()
+}
+
+func (q *TotalOrderQueue[T]) ready() []T {
+	var ready []T
+	for len(q.pending) > 0 {
+		head := q.pending[0]
+		for _, member := range q.members {
+			if !q.acks[head.key][member] {
+				return ready
+			}
+		}
+		delete(q.acks, head.key)
+		q.pending = q.pending[1:]
+		ready = append(ready, head.msg)
+	}
+	return ready
+}
+
+// Len returns the number of messages waiting to be delivered.
+func (q *TotalOrderQueue[T]) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.pending)
+}
@@ -0,0 +1,70 @@
+package delivery
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestTotalOrderQueueWaitsForEveryAck(t *testing.T) {
+	q := NewTotalOrderQueue[string]([]int{1, 2, 3})
+	key := Key{Timestamp: 5, Sender: 2}
+	if got := q.Add(key, "m"); len(got) != 0 {
+		t.Fatalf("delivered %v without acknowledgements", got)
+	}
+	for _, member := range []int{1, 2} {
+		if got := q.Ack(key, member); len(got) != 0 {
+			t.Fatalf("delivered %v after the ack of member %d only", got, member)
+		}
+	}
+	if got := q.Ack(key, 3); !slices.Equal(got, []string{"m"}) {
+		t.Fatalf("delivered %v, want [m]", got)
+	}
+	if q.Len() != 0 {
+		t.Errorf("Len() = %d, want 0", q.Len())
+	}
+}
+
+func TestTotalOrderQueueDeliversInKeyOrder(t *testing.T) {
+	q := NewTotalOrderQueue[string]([]int{1, 2})
+	late, early, tie := Key{Timestamp: 7, Sender: 1}, Key{Timestamp: 3, Sender: 2}, Key{Timestamp: 7, Sender: 2}
+	q.Add(late, "late")
+	q.Add(tie, "tie")
+	q.Add(early, "early")
+	// Every member acknowledges the later messages first, which must still wait for the earlier one
+	for _, member := range []int{1, 2} {
+		if got := append(q.Ack(late, member), q.Ack(tie, member)...); len(got) != 0 {
+			t.Fatalf("delivered %v before the earlier message", got)
+		}
+	}
+	q.Ack(early, 1)
+	if got := q.Ack(early, 2); !slices.Equal(got, []string{"early", "late", "tie"}) {
+		t.Fatalf("delivered %v, want [early late tie]", got)
+	}
+}
+
+func TestTotalOrderQueueAcksBeforeTheMessage(t *testing.T) {
+	q := NewTotalOrderQueue[string]([]int{1, 2})
+	key := Key{Timestamp: 1, Sender: 1}
+	q.Ack(key, 1)
+	q.Ack(key, 2)
+	if got := q.Add(key, "m"); !slices.Equal(got, []string{"m"}) {
+		t.Fatalf("delivered %v, want [m]", got)
+	}
+}
+
+func TestKeyLess(t *testing.T) {
+	tests := []struct {
+		a, b Key
+		want bool
+	}{
+		{Key{1, 2}, Key{2, 1}, true},
+		{Key{2, 1}, Key{1, 2}, false},
+		{Key{2, 1}, Key{2, 2}, true},
+		{Key{2, 2}, Key{2, 2}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.a.Less(tt.b); got != tt.want {
+			t.Errorf("%v.Less(%v) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+// Package detector provides the failure detectors the Q2 ring processes use
+// to decide, from the heartbeats they receive alone, whether a process they
+// monitor has crashed.
+package detector
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Detector watches the heartbeats of one monitored process. Detectors are
+// used by the goroutine of a single process and need not be safe for
+// concurrent use.
+type Detector interface {
+	// Heartbeat records a heartbeat that arrived at now.
+	Heartbeat(now time.Time)
+	// Level returns how strongly the monitored process is suspected at now.
+	// Its scale depends on the detector.
+	Level(now time.Time) float64
+	// Suspect reports whether the monitored process is suspected to have
+	// crashed at now.
+	Suspect(now time.Time) bool
+}
+
+// New returns a detector for a process that is watched from start on, as if
+// a heartbeat had arrived then.
+type New func(start time.Time) Detector
+
+type timeout struct {
+	d    time.Duration
+	last time.Time
+}
+
+// Timeout returns detectors that suspect the monitored process once d has
+// passed without a heartbeat.
+func Timeout(d time.Duration) New {
+	return func(start time.Time) Detector {
+		return &timeout{d: d, last: start}
+	}
+}
+
+func (t *timeout) Heartbeat(now time.Time) {
+	t.last = now
+}
+
+// The level is the time since the last heartbeat in timeouts, so that the
+// process is suspected from 1 on
+func (t *timeout) Level(now time.Time) float64 {
+	return float64(now.Sub(t.last)) / float64(t.d)
+}
+
+func (t *timeout) Suspect(now time.Time) bool {
+	return now.Sub(t.last) >= t.d
+}
+
+type phiAccrual struct {
+	threshold float64
+	window    int
+	minStdDev float64
+	intervals []float64 // Latest intervals between two heartbeats, in seconds
+	sum       float64
+	sumSq     float64
+	last      time.Time
+}
+
+// PhiAccrual returns phi accrual failure detectors. Each one keeps the
+// intervals between the latest window heartbeats and computes phi, the
+// suspicion level, from the time since the last heartbeat: phi is -log10 of
+// the probability that a heartbeat arrives even later, under a normal
+// distribution with the mean and standard deviation of the intervals. The
+// process is suspected once phi reaches threshold, so a threshold of 8 is
+// wrong about once in 10^8 suspicions if the intervals are normal.
+//
+// Until there are heartbeats the intervals are taken to be expected with a
+// standard deviation of a quarter of it. The standard deviation is never
+// below a tenth of expected, so that perfectly regular heartbeats do not make
+// phi jump from 0 to infinity.
+func PhiAccrual(threshold float64, window int, expected time.Duration) New {
+	return func(start time.Time) Detector {
+		d := &phiAccrual{threshold: threshold, window: window, minStdDev: expected.Seconds() / 10, last: start}
+		d.add(expected.Seconds() * 3 / 4)
+		d.add(expected.Seconds() * 5 / 4)
+		return d
+	}
+}
+
+func (d *phiAccrual) add(interval float64) {
+	if len(d.intervals) == d.window {
+		d.sum -= d.intervals[0]
+		d.sumSq -= d.intervals[0] * d.intervals[0]
+		d.intervals = d.intervals[1:]
+	}
+	d.intervals = append(d.intervals, interval)
+	d.sum += interval
+	d.sumSq += interval * interval
+}
+
+func (d *phiAccrual) Heartbeat(now time.Time) {
+	d.add(now.Sub(d.last).Seconds())
+	d.last = now
+}
+
+func (d *phiAccrual) Level(now time.Time) float64 {
+	n := float64(len(d.intervals))
+	mean := d.sum / n
+	stdDev := math.Sqrt(math.Max(d.sumSq/n-mean*mean, 0))
+	stdDev = math.Max(stdDev, d.minStdDev)
+	y := (now.Sub(d.last).Seconds() - mean) / stdDev
+	later := 0.5 * math.Erfc(y/math.Sqrt2) // Probability that the heartbeat arrives even later
+	return -math.Log10(later)
+}
+
+func (d *phiAccrual) Suspect(now time.Time) bool {
+	return d.Level(now) >= d.threshold
+}
+
+// Usage describes the detector specifications accepted by Parse.
+const Usage = `failure detector of the coordinator's heartbeats, one of:
+  timeout:D              suspect the coordinator after D without a heartbeat
+  D                      the same as timeout:D
+  phi:T[:N]              phi accrual over the latest N heartbeats (100), suspect once phi reaches T (8 is usual)`
+
+// Parse builds detectors from a specification as described by Usage.
+// interval is the time between two heartbeats the sender aims for.
+func Parse(spec string, interval time.Duration) (New, error) {
+	kind, args, _ := strings.Cut(strings.TrimSpace(spec), ":")
+	if d, err := parseTimeout(spec); err == nil {
+		return Timeout(d), nil
+	}
+	switch kind {
+	case "timeout":
+		d, err := parseTimeout(args)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", spec, err)
+		}
+		return Timeout(d), nil
+	case "phi":
+		threshold, size, hasWindow := strings.Cut(args, ":")
+		t, err := strconv.ParseFloat(threshold, 64)
+		if err != nil || t <= 0 {
+			return nil, fmt.Errorf("%s: invalid threshold %q", spec, threshold)
+		}
+		window := 100
+		if hasWindow {
+			if window, err = strconv.Atoi(size); err != nil || window < 2 {
+				return nil, fmt.Errorf("%s: invalid window %q, it must be at least 2", spec, size)
+			}
+		}
+		return PhiAccrual(t, window, interval), nil
+	}
+	return nil, fmt.Errorf("unknown failure detector %q", spec)
+}
+
+func parseTimeout(s string) (time.Duration, error) {
+	d, err := time.ParseDuration(strings.TrimSpace(s))
+	if err != nil {
+		return 0, err
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("timeout %v is not positive", d)
+	}
+	return d, nil
+}
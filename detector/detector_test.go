@@ -0,0 +1,121 @@
+package detector
+
+import (
+	"testing"
+	"time"
+)
+
+var start = time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// Step of a detector test: a heartbeat at the given time after start, or a
+// check whether the detector suspects the monitored process then
+type step struct {
+	at        time.Duration
+	heartbeat bool
+	suspect   bool
+}
+
+func heartbeat(at time.Duration) step           { return step{at: at, heartbeat: true} }
+func check(at time.Duration, suspect bool) step { return step{at: at, suspect: suspect} }
+
+func run(t *testing.T, d Detector, steps []step) {
+	t.Helper()
+	for i, s := range steps {
+		if s.heartbeat {
+			d.Heartbeat(start.Add(s.at))
+			continue
+		}
+		if got := d.Suspect(start.Add(s.at)); got != s.suspect {
+			t.Errorf("step %d: Suspect at %v = %v, want %v", i+1, s.at, got, s.suspect)
+		}
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{"trusts until the timeout", []step{check(0, false), check(9*time.Second, false)}},
+		{"suspects once the timeout passes", []step{check(10*time.Second, true), check(time.Minute, true)}},
+		{"heartbeats put the timeout off", []step{heartbeat(4 * time.Second), heartbeat(8 * time.Second), check(17*time.Second, false), check(18*time.Second, true)}},
+		{"trusts again after a late heartbeat", []step{check(15*time.Second, true), heartbeat(16 * time.Second), check(16*time.Second, false), check(25*time.Second, false), check(26*time.Second, true)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			run(t, Timeout(10*time.Second)(start), tt.steps)
+		})
+	}
+}
+
+// Heartbeats every 4s from start on, up to and including at
+func regular(at time.Duration) []step {
+	var steps []step
+	for t := 4 * time.Second; t <= at; t += 4 * time.Second {
+		steps = append(steps, heartbeat(t))
+	}
+	return steps
+}
+
+func TestPhiAccrual(t *testing.T) {
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{"trusts a heartbeat that is on time", append(regular(40*time.Second), check(44*time.Second, false))},
+		{"suspects a long silence", append(regular(40*time.Second), check(60*time.Second, true))},
+		{"trusts again after a late heartbeat", append(regular(40*time.Second), check(60*time.Second, true), heartbeat(61*time.Second), check(62*time.Second, false))},
+		{"trusts before the first heartbeat", []step{check(4*time.Second, false)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			run(t, PhiAccrual(8, 100, 4*time.Second)(start), tt.steps)
+		})
+	}
+}
+
+// Phi only grows while no heartbeat arrives, and falls back once one does
+func TestPhiAccrualGrowsWithTheSilence(t *testing.T) {
+	d := PhiAccrual(8, 100, 4*time.Second)(start)
+	run(t, d, regular(40*time.Second))
+	last := start.Add(40 * time.Second)
+	previous := d.Level(last)
+	for since := 100 * time.Millisecond; since <= 30*time.Second; since += 100 * time.Millisecond {
+		level := d.Level(last.Add(since))
+		if level < previous {
+			t.Fatalf("phi fell from %v to %v at %v after the last heartbeat", previous, level, since)
+		}
+		previous = level
+	}
+	if !d.Suspect(last.Add(30 * time.Second)) {
+		t.Errorf("phi is %v after 30s of silence, want it past the threshold", previous)
+	}
+	d.Heartbeat(last.Add(30 * time.Second))
+	if level := d.Level(last.Add(31 * time.Second)); level >= previous {
+		t.Errorf("phi is %v after a heartbeat, want it below %v", level, previous)
+	}
+}
+
+func TestParse(t *testing.T) {
+	for _, spec := range []string{"timeout:10s", "10s", " timeout:10s "} {
+		newDetector, err := Parse(spec, 4*time.Second)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", spec, err)
+			continue
+		}
+		run(t, newDetector(start), []step{check(9*time.Second, false), check(10*time.Second, true)})
+	}
+	for _, spec := range []string{"phi:8", "phi:8:10"} {
+		newDetector, err := Parse(spec, 4*time.Second)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", spec, err)
+			continue
+		}
+		run(t, newDetector(start), append(regular(40*time.Second), check(44*time.Second, false), check(80*time.Second, true)))
+	}
+	for _, spec := range []string{"", "timeout", "timeout:0s", "timeout:-1s", "-1s", "soon", "never:10s", "phi", "phi:0", "phi:-2", "phi:8:1", "phi:8:many"} {
+		if _, err := Parse(spec, 4*time.Second); err == nil {
+			t.Errorf("Parse(%q) succeeded, want an error", spec)
+		}
+	}
+}
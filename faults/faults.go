@@ -0,0 +1,193 @@
+// Package faults describes when the Q2 ring programs crash their processes,
+// when they recover and when the network between them is partitioned, so that
+// a crash scenario can be given on the command line instead of being written
+// into each program.
+package faults
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Jashveragiwala/Lamport-and-Vector-Clocks/sim"
+)
+
+// Processes that are only known when a crash happens.
+const (
+	Coordinator = -1 // The coordinator at the time of the crash
+	Random      = -2 // Any process, which may have crashed already
+	Other       = -3 // An active process other than the coordinator, or the coordinator once it is the last one
+	Crashed     = -4 // A process that has crashed, only for recoveries
+)
+
+// Crash crashes Process, or a process picked as described above, At after
+// the start of the run and then again Every after that, unless Every is 0.
+// If Recover is set the process recovers instead.
+type Crash struct {
+	Process int
+	At      time.Duration
+	Every   time.Duration
+	Recover bool
+}
+
+// Choose returns the ID of the process the crash is for, given the number of
+// processes, which are numbered from 1, the IDs of the active ones and the
+// coordinator. Random choices are made by intn, which returns a number in
+// [0, n). It returns 0 when there is no process to crash.
+func (c Crash) Choose(processes int, active []int, coordinator int, intn func(n int) int) int {
+	switch c.Process {
+	case Coordinator:
+		return coordinator
+	case Random:
+		return intn(processes) + 1
+	case Other:
+		var others []int
+		for _, id := range active {
+			if id != coordinator {
+				others = append(others, id)
+			}
+		}
+		if len(others) == 0 {
+			if len(active) == 1 {
+				return active[0]
+			}
+			return 0
+		}
+		return others[intn(len(others))]
+	case Crashed:
+		var crashed []int
+		for id := 1; id <= processes; id++ {
+			if !slices.Contains(active, id) {
+				crashed = append(crashed, id)
+			}
+		}
+		if len(crashed) == 0 {
+			return 0
+		}
+		return crashed[intn(len(crashed))]
+	}
+	return c.Process
+}
+
+// Run schedules every crash and recovery of the schedule, calling crash
+// whenever one is due, until stop reports that the run is over. Neither
+// function is called concurrently with another call of either.
+func Run(schedule []Crash, scheduler sim.Scheduler, crash func(Crash), stop func() bool) {
+	var mu sync.Mutex
+	for _, c := range schedule {
+		c := c
+		var fire func()
+		fire = func() {
+			mu.Lock()
+			defer mu.Unlock()
+			if stop() {
+				return
+			}
+			crash(c)
+			if c.Every > 0 {
+				scheduler.AfterFunc(c.Every, fire)
+			}
+		}
+		scheduler.AfterFunc(c.At, fire)
+	}
+}
+
+// CrashesAll reports whether the schedule goes on crashing processes until
+// every one of the given number of processes has crashed, so that a run which
+// ends once they all have is sure to end. That takes a repeating crash of the
+// coordinator, a random or an other process, or a crash of every process ID,
+// and no recoveries, which may bring processes back after the last crash.
+func CrashesAll(schedule []Crash, processes int) bool {
+	if Recovers(schedule) {
+		return false
+	}
+	crashed := make(map[int]bool)
+	for _, c := range schedule {
+		if c.Process < 0 && c.Every > 0 {
+			return true
+		}
+		if c.Process >= 1 && c.Process <= processes {
+			crashed[c.Process] = true
+		}
+	}
+	return len(crashed) == processes
+}
+
+// Usage describes the crash schedules accepted by Parse.
+const Usage = `crash schedule, a comma-separated list of WHO@AT[/EVERY]:
+  WHO is a process ID, "coordinator", "random" (any process, even one that has crashed)
+  or "other" (an active process other than the coordinator, or the last process left);
+  the crash happens AT after the start and again EVERY after that, e.g. coordinator@10s,random@15s/5s`
+
+// RecoveryUsage describes the schedules accepted by ParseWithRecoveries.
+const RecoveryUsage = Usage + `;
+  recover:WHO@AT[/EVERY] recovers a process instead, WHO being a process ID, "random" (any process,
+  even one that is active) or "crashed" (a process that has crashed), e.g. random@10s/5s,recover:crashed@12s/5s`
+
+// Parse builds a crash schedule from a specification as described by Usage.
+func Parse(spec string) ([]Crash, error) {
+	return parse(spec, false)
+}
+
+// ParseWithRecoveries builds a schedule of crashes and recoveries from a
+// specification as described by RecoveryUsage.
+func ParseWithRecoveries(spec string) ([]Crash, error) {
+	return parse(spec, true)
+}
+
+// Recovers reports whether the schedule recovers any process.
+func Recovers(schedule []Crash) bool {
+	for _, c := range schedule {
+		if c.Recover {
+			return true
+		}
+	}
+	return false
+}
+
+func parse(spec string, recoveries bool) ([]Crash, error) {
+	var schedule []Crash
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		var c Crash
+		entry := part
+		if recoveries {
+			entry, c.Recover = strings.CutPrefix(part, "recover:")
+		}
+		who, when, ok := strings.Cut(entry, "@")
+		if !ok {
+			return nil, fmt.Errorf("crash %q: expected WHO@AT", part)
+		}
+		switch {
+		case who == "coordinator" && !c.Recover:
+			c.Process = Coordinator
+		case who == "random":
+			c.Process = Random
+		case who == "other" && !c.Recover:
+			c.Process = Other
+		case who == "crashed" && c.Recover:
+			c.Process = Crashed
+		default:
+			id, err := strconv.Atoi(who)
+			if err != nil || id < 1 {
+				return nil, fmt.Errorf("crash %q: invalid process %q", part, who)
+			}
+			c.Process = id
+		}
+		at, every, repeats := strings.Cut(when, "/")
+		var err error
+		if c.At, err = time.ParseDuration(at); err != nil || c.At < 0 {
+			return nil, fmt.Errorf("crash %q: invalid time %q", part, at)
+		}
+		if repeats {
+			if c.Every, err = time.ParseDuration(every); err != nil || c.Every <= 0 {
+				return nil, fmt.Errorf("crash %q: invalid interval %q", part, every)
+			}
+		}
+		schedule = append(schedule, c)
+	}
+	return schedule, nil
+}
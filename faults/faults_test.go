@@ -0,0 +1,140 @@
+package faults
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/Jashveragiwala/Lamport-and-Vector-Clocks/sim"
+)
+
+func TestParse(t *testing.T) {
+	got, err := Parse("coordinator@10s, random@15s/5s,other@1m,3@0s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Crash{
+		{Process: Coordinator, At: 10 * time.Second},
+		{Process: Random, At: 15 * time.Second, Every: 5 * time.Second},
+		{Process: Other, At: time.Minute},
+		{Process: 3},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Parse = %+v, want %+v", got, want)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, spec := range []string{"", "coordinator", "nobody@1s", "0@1s", "2@soon", "2@-1s", "2@1s/0s", "recover:2@1s", "crashed@1s"} {
+		if _, err := Parse(spec); err == nil {
+			t.Errorf("Parse(%q) succeeded, want an error", spec)
+		}
+	}
+}
+
+func TestChoose(t *testing.T) {
+	first := func(n int) int { return 0 }
+	last := func(n int) int { return n - 1 }
+	tests := []struct {
+		name        string
+		process     int
+		active      []int
+		coordinator int
+		intn        func(int) int
+		want        int
+	}{
+		{"given process", 2, []int{1, 3}, 3, first, 2},
+		{"coordinator", Coordinator, []int{1, 2, 3}, 3, first, 3},
+		{"random may pick a crashed process", Random, []int{1, 2}, 2, last, 3},
+		{"other skips the coordinator", Other, []int{1, 2, 3}, 3, last, 2},
+		{"other is the last process left", Other, []int{2}, 2, first, 2},
+		{"other without active processes", Other, nil, 0, first, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := (Crash{Process: tt.process}).Choose(3, tt.active, tt.coordinator, tt.intn); got != tt.want {
+				t.Errorf("Choose = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunFiresCrashesUntilStopped(t *testing.T) {
+	engine := sim.NewEngine()
+	var fired []string
+	schedule := []Crash{{Process: Coordinator, At: 10 * time.Second}, {Process: Random, At: 15 * time.Second, Every: 5 * time.Second}}
+	Run(schedule, engine, func(c Crash) {
+		fired = append(fired, fmt.Sprintf("%d@%v", c.Process, engine.Elapsed()))
+	}, func() bool { return len(fired) == 4 })
+	engine.Run(time.Hour)
+
+	want := []string{"-1@10s", "-2@15s", "-2@20s", "-2@25s"}
+	if !slices.Equal(fired, want) {
+		t.Errorf("crashes fired as %v, want %v", fired, want)
+	}
+	if engine.Elapsed() != 30*time.Second {
+		t.Errorf("the schedule ran until %v, want it to stop at 30s once the run was over", engine.Elapsed())
+	}
+}
+
+func TestCrashesAll(t *testing.T) {
+	tests := []struct {
+		spec string
+		want bool
+	}{
+		{"random@10s/20s", true},
+		{"coordinator@10s,other@20s/10s", true},
+		{"coordinator@10s/30s", true},
+		{"coordinator@10s,random@15s", false},
+		{"3@10s/5s", false},
+		{"1@10s,2@20s,3@30s", true},
+		{"1@10s,2@20s,2@30s", false},
+		{"1@10s,2@20s,3@30s,4@40s", true},
+		{"random@10s/20s,recover:crashed@15s/20s", false},
+		{"1@10s,2@20s,3@30s,recover:2@25s", false},
+	}
+	for _, tt := range tests {
+		schedule, err := ParseWithRecoveries(tt.spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := CrashesAll(schedule, 3); got != tt.want {
+			t.Errorf("CrashesAll(%q, 3) = %v, want %v", tt.spec, got, tt.want)
+		}
+	}
+}
+
+func TestParseWithRecoveries(t *testing.T) {
+	got, err := ParseWithRecoveries("random@10s/5s,recover:crashed@12s/5s,recover:2@20s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Crash{
+		{Process: Random, At: 10 * time.Second, Every: 5 * time.Second},
+		{Process: Crashed, At: 12 * time.Second, Every: 5 * time.Second, Recover: true},
+		{Process: 2, At: 20 * time.Second, Recover: true},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseWithRecoveries = %+v, want %+v", got, want)
+	}
+	if !Recovers(got) || Recovers(got[:1]) {
+		t.Errorf("Recovers does not tell the schedules apart")
+	}
+	for _, spec := range []string{"recover:coordinator@1s", "recover:other@1s", "crashed@1s"} {
+		if _, err := ParseWithRecoveries(spec); err == nil {
+			t.Errorf("ParseWithRecoveries(%q) succeeded, want an error", spec)
+		}
+	}
+}
+
+func TestChooseCrashed(t *testing.T) {
+	recovery := Crash{Process: Crashed, Recover: true}
+	if got := recovery.Choose(4, []int{1, 3}, 3, func(n int) int { return n - 1 }); got != 4 {
+		t.Errorf("Choose = %d, want 4", got)
+	}
+	if got := recovery.Choose(2, []int{1, 2}, 2, func(n int) int { return 0 }); got != 0 {
+		t.Errorf("Choose without crashed processes = %d, want 0", got)
+	}
+}
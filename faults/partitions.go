@@ -0,0 +1,87 @@
+package faults
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Partition splits the processes into Groups that cannot reach each other At
+// after the start of the run. A nil Groups heals the partition instead.
+type Partition struct {
+	Groups [][]int
+	At     time.Duration
+}
+
+// PartitionUsage describes the partition schedules accepted by ParsePartitions.
+const PartitionUsage = `network partitions, a comma-separated list of GROUP|GROUP...@AT or heal@AT:
+  a GROUP is a "+"-separated list of process IDs and ranges FROM-TO, and a process
+  in no group is cut off from every other one; e.g. 1-3|4+5@20s,heal@60s`
+
+// ParsePartitions builds a partition schedule from a specification as
+// described by PartitionUsage.
+func ParsePartitions(spec string) ([]Partition, error) {
+	var schedule []Partition
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		groups, when, ok := strings.Cut(part, "@")
+		if !ok {
+			return nil, fmt.Errorf("partition %q: expected GROUPS@AT", part)
+		}
+		var p Partition
+		var err error
+		if p.At, err = time.ParseDuration(when); err != nil || p.At < 0 {
+			return nil, fmt.Errorf("partition %q: invalid time %q", part, when)
+		}
+		if groups != "heal" {
+			if p.Groups, err = parseGroups(groups); err != nil {
+				return nil, fmt.Errorf("partition %q: %v", part, err)
+			}
+		}
+		schedule = append(schedule, p)
+	}
+	return schedule, nil
+}
+
+// MaxProcess returns the highest process ID in the schedule, 0 if there is
+// none.
+func MaxProcess(schedule []Partition) int {
+	highest := 0
+	for _, p := range schedule {
+		for _, group := range p.Groups {
+			for _, id := range group {
+				highest = max(highest, id)
+			}
+		}
+	}
+	return highest
+}
+
+func parseGroups(spec string) ([][]int, error) {
+	var groups [][]int
+	seen := make(map[int]bool)
+	for _, group := range strings.Split(spec, "|") {
+		var ids []int
+		for _, member := range strings.Split(group, "+") {
+			from, to, isRange := strings.Cut(strings.TrimSpace(member), "-")
+			first, err1 := strconv.Atoi(from)
+			last, err2 := first, error(nil)
+			if isRange {
+				last, err2 = strconv.Atoi(to)
+			}
+			if err1 != nil || err2 != nil || first < 1 || last < first {
+				return nil, fmt.Errorf("invalid processes %q", member)
+			}
+			for id := first; id <= last; id++ {
+				if seen[id] {
+					return nil, fmt.Errorf("process %d is in two groups", id)
+				}
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+		groups = append(groups, ids)
+	}
+	return groups, nil
+}
@@ -0,0 +1,33 @@
+package faults
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParsePartitions(t *testing.T) {
+	got, err := ParsePartitions("1-3|4+5@20s, 2|1+3-4@40s,heal@1m")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Partition{
+		{Groups: [][]int{{1, 2, 3}, {4, 5}}, At: 20 * time.Second},
+		{Groups: [][]int{{2}, {1, 3, 4}}, At: 40 * time.Second},
+		{At: time.Minute},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParsePartitions = %+v, want %+v", got, want)
+	}
+	if highest := MaxProcess(got); highest != 5 {
+		t.Errorf("MaxProcess = %d, want 5", highest)
+	}
+}
+
+func TestParsePartitionsErrors(t *testing.T) {
+	for _, spec := range []string{"", "1|2", "1|2@soon", "1|2@-1s", "0|1@1s", "3-1|4@1s", "1-3|3+4@1s", "1|x@1s", "1||2@1s"} {
+		if _, err := ParsePartitions(spec); err == nil {
+			t.Errorf("ParsePartitions(%q) succeeded, want an error", spec)
+		}
+	}
+}
@@ -0,0 +1,3 @@
+module github.com/Jashveragiwala/Lamport-and-Vector-Clocks
+
+go 1.21
@@ -0,0 +1,265 @@
+// Package loss provides the policies the Q1 servers use to decide which
+// client messages to drop instead of forwarding.
+package loss
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// DropPolicy decides which receivers a message is not forwarded to.
+// Policies are called by a single server goroutine and need not be safe for
+// concurrent use.
+type DropPolicy interface {
+	// Drop returns the receivers, out of receivers, that the message with
+	// messageID from sender is dropped for.
+	Drop(sender, messageID int, receivers []int) []int
+}
+
+// Rand is the source of randomness used by the probabilistic policies.
+// *rand.Rand satisfies it.
+type Rand interface {
+	Float64() float64
+}
+
+type never struct{}
+
+// Never returns a policy that forwards every message.
+func Never() DropPolicy {
+	return never{}
+}
+
+func (never) Drop(sender, messageID int, receivers []int) []int {
+	return nil
+}
+
+type fixed struct {
+	p   float64
+	rng Rand
+}
+
+// Fixed returns a policy that drops each message for all receivers with
+// probability p. Fixed(0.5, rng) is the coin toss the servers always used.
+func Fixed(p float64, rng Rand) DropPolicy {
+	return fixed{p, rng}
+}
+
+func (f fixed) Drop(sender, messageID int, receivers []int) []int {
+	if f.rng.Float64() < f.p {
+		return receivers
+	}
+	return nil
+}
+
+type perSender struct {
+	p   map[int]float64
+	rng Rand
+}
+
+// PerSender returns a policy that drops each message for all receivers with
+// the probability given for its sender. Senders without a probability are
+// never dropped.
+func PerSender(p map[int]float64, rng Rand) DropPolicy {
+	return perSender{p, rng}
+}
+
+func (s perSender) Drop(sender, messageID int, receivers []int) []int {
+	if p, ok := s.p[sender]; ok && s.rng.Float64() < p {
+		return receivers
+	}
+	return nil
+}
+
+type perReceiver struct {
+	p   map[int]float64
+	rng Rand
+}
+
+// PerReceiver returns a policy that drops each message independently for
+// every receiver with the probability given for that receiver. Receivers
+// without a probability always get the message.
+func PerReceiver(p map[int]float64, rng Rand) DropPolicy {
+	return perReceiver{p, rng}
+}
+
+func (r perReceiver) Drop(sender, messageID int, receivers []int) []int {
+	var dropped []int
+	for _, receiver := range receivers {
+		if p, ok := r.p[receiver]; ok && r.rng.Float64() < p {
+			dropped = append(dropped, receiver)
+		}
+	}
+	return dropped
+}
+
+type every struct {
+	n      int
+	sender int
+	seen   map[int]int
+}
+
+// Every returns a policy that drops every nth message the server receives
+// from sender, or from each sender if sender is 0, for all receivers.
+func Every(n, sender int) DropPolicy {
+	return &every{n: n, sender: sender, seen: make(map[int]int)}
+}
+
+func (e *every) Drop(sender, messageID int, receivers []int) []int {
+	if e.sender != 0 && sender != e.sender {
+		return nil
+	}
+	e.seen[sender]++
+	if e.seen[sender]%e.n == 0 {
+		return receivers
+	}
+	return nil
+}
+
+// Message identifies a message by its sender and message ID.
+type Message struct {
+	Sender    int
+	MessageID int
+}
+
+type messages map[Message]bool
+
+// Messages returns a policy that drops exactly the given messages for all
+// receivers.
+func Messages(list ...Message) DropPolicy {
+	m := make(messages)
+	for _, msg := range list {
+		m[msg] = true
+	}
+	return m
+}
+
+func (m messages) Drop(sender, messageID int, receivers []int) []int {
+	if m[Message{sender, messageID}] {
+		return receivers
+	}
+	return nil
+}
+
+type anyOf []DropPolicy
+
+// Any returns a policy that drops a message for a receiver whenever one of
+// the policies does. Every policy is consulted for every message.
+func Any(policies ...DropPolicy) DropPolicy {
+	return anyOf(policies)
+}
+
+func (a anyOf) Drop(sender, messageID int, receivers []int) []int {
+	dropped := make(map[int]bool)
+	for _, policy := range a {
+		for _, receiver := range policy.Drop(sender, messageID, receivers) {
+			dropped[receiver] = true
+		}
+	}
+	var list []int
+	for _, receiver := range receivers {
+		if dropped[receiver] {
+			list = append(list, receiver)
+		}
+	}
+	return list
+}
+
+// Usage describes the policy specifications accepted by Parse.
+const Usage = `message loss policy, one or more of the following joined by "+":
+  never                  forward every message
+  fixed:P                drop each message with probability P (fixed:0.5 is a coin toss)
+  P                      the same as fixed:P
+  sender:ID=P,...        drop each message from client ID with probability P
+  receiver:ID=P,...      drop each message for client ID with probability P
+  every:N[@ID]           drop every Nth message from client ID, or from every client
+  messages:ID/M,...      drop message M from client ID`
+
+// Parse builds a policy from a specification as described by Usage.
+func Parse(spec string, rng Rand) (DropPolicy, error) {
+	var policies []DropPolicy
+	for _, part := range strings.Split(spec, "+") {
+		policy, err := parseOne(strings.TrimSpace(part), rng)
+		if err != nil {
+			return nil, err
+		}
+		policies = append(policies, policy)
+	}
+	if len(policies) == 1 {
+		return policies[0], nil
+	}
+	return Any(policies...), nil
+}
+
+func parseOne(spec string, rng Rand) (DropPolicy, error) {
+	kind, args, _ := strings.Cut(spec, ":")
+	if p, err := parseProbability(spec); err == nil {
+		return Fixed(p, rng), nil
+	}
+	switch kind {
+	case "never":
+		return Never(), nil
+	case "fixed":
+		p, err := parseProbability(args)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", spec, err)
+		}
+		return Fixed(p, rng), nil
+	case "sender", "receiver":
+		p := make(map[int]float64)
+		for _, pair := range strings.Split(args, ",") {
+			id, value, ok := strings.Cut(pair, "=")
+			if !ok {
+				return nil, fmt.Errorf("%s: expected ID=P, got %q", spec, pair)
+			}
+			client, err := strconv.Atoi(id)
+			if err != nil {
+				return nil, fmt.Errorf("%s: invalid client %q", spec, id)
+			}
+			if p[client], err = parseProbability(value); err != nil {
+				return nil, fmt.Errorf("%s: %w", spec, err)
+			}
+		}
+		if kind == "sender" {
+			return PerSender(p, rng), nil
+		}
+		return PerReceiver(p, rng), nil
+	case "every":
+		count, sender, hasSender := strings.Cut(args, "@")
+		n, err := strconv.Atoi(count)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("%s: invalid count %q", spec, count)
+		}
+		client := 0
+		if hasSender {
+			if client, err = strconv.Atoi(sender); err != nil || client < 1 {
+				return nil, fmt.Errorf("%s: invalid client %q", spec, sender)
+			}
+		}
+		return Every(n, client), nil
+	case "messages":
+		var list []Message
+		for _, pair := range strings.Split(args, ",") {
+			id, messageID, ok := strings.Cut(pair, "/")
+			if !ok {
+				return nil, fmt.Errorf("%s: expected ID/M, got %q", spec, pair)
+			}
+			client, err1 := strconv.Atoi(id)
+			message, err2 := strconv.Atoi(messageID)
+			if err1 != nil || err2 != nil {
+				return nil, fmt.Errorf("%s: invalid message %q", spec, pair)
+			}
+			list = append(list, Message{client, message})
+		}
+		return Messages(list...), nil
+	}
+	return nil, fmt.Errorf("unknown message loss policy %q", spec)
+}
+
+func parseProbability(s string) (float64, error) {
+	p, err := strconv.ParseFloat(s, 64)
+	if err != nil || p < 0 || p > 1 {
+		return 0, fmt.Errorf("invalid probability %q", s)
+	}
+	return p, nil
+}
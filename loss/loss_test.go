@@ -0,0 +1,55 @@
+package loss
+
+import (
+	"slices"
+	"testing"
+)
+
+// constant is a Rand that always returns the same value
+type constant float64
+
+func (c constant) Float64() float64 { return float64(c) }
+
+func TestParse(t *testing.T) {
+	receivers := []int{1, 2, 3}
+	type message struct {
+		sender, messageID int
+		want              []int
+	}
+	tests := []struct {
+		spec     string
+		rng      Rand
+		messages []message
+	}{
+		{"never", constant(0), []message{{1, 1, nil}}},
+		{"0.5", constant(0.4), []message{{1, 1, receivers}}},
+		{"fixed:0.5", constant(0.6), []message{{1, 1, nil}}},
+		{"sender:2=0.9", constant(0.5), []message{{1, 1, nil}, {2, 1, receivers}}},
+		{"receiver:1=0.9,3=0.1", constant(0.5), []message{{2, 1, []int{1}}}},
+		{"every:2", constant(0), []message{{1, 1, nil}, {2, 1, nil}, {1, 2, receivers}, {2, 2, receivers}}},
+		{"every:2@1", constant(0), []message{{1, 1, nil}, {2, 1, nil}, {2, 2, nil}, {1, 2, receivers}}},
+		{"messages:1/2,3/1", constant(0), []message{{1, 1, nil}, {1, 2, receivers}, {3, 1, receivers}}},
+		{"receiver:2=1 + messages:1/1", constant(0.5), []message{{1, 1, receivers}, {1, 2, []int{2}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			policy, err := Parse(tt.spec, tt.rng)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, m := range tt.messages {
+				if got := policy.Drop(m.sender, m.messageID, receivers); !slices.Equal(got, m.want) {
+					t.Errorf("Drop(%d, %d) = %v, want %v", m.sender, m.messageID, got, m.want)
+				}
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, spec := range []string{"", "sometimes", "1.5", "fixed:x", "sender:2", "sender:x=0.5", "every:0", "every:2@0", "messages:1", "never+"} {
+		if _, err := Parse(spec, constant(0)); err == nil {
+			t.Errorf("Parse(%q) succeeded, want an error", spec)
+		}
+	}
+}
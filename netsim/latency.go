@@ -0,0 +1,58 @@
+package netsim
+
+import "time"
+
+// Distribution is a distribution of message latencies.
+type Distribution interface {
+	Sample(rng Rand) time.Duration
+}
+
+type constant time.Duration
+
+// Constant returns a distribution that is always d.
+func Constant(d time.Duration) Distribution {
+	return constant(d)
+}
+
+func (c constant) Sample(rng Rand) time.Duration {
+	return time.Duration(c)
+}
+
+type uniform struct {
+	min, max time.Duration
+}
+
+// Uniform returns a distribution uniform over [min, max).
+func Uniform(min, max time.Duration) Distribution {
+	return uniform{min, max}
+}
+
+func (u uniform) Sample(rng Rand) time.Duration {
+	return u.min + time.Duration(rng.Float64()*float64(u.max-u.min))
+}
+
+type normal struct {
+	mean, stddev time.Duration
+}
+
+// Normal returns a normal distribution with the given mean and standard
+// deviation. Negative samples are treated as zero by the network.
+func Normal(mean, stddev time.Duration) Distribution {
+	return normal{mean, stddev}
+}
+
+func (n normal) Sample(rng Rand) time.Duration {
+	return n.mean + time.Duration(rng.NormFloat64()*float64(n.stddev))
+}
+
+type exponential time.Duration
+
+// Exponential returns an exponential distribution with the given mean, which
+// gives the long tail of a congested link.
+func Exponential(mean time.Duration) Distribution {
+	return exponential(mean)
+}
+
+func (e exponential) Sample(rng Rand) time.Duration {
+	return time.Duration(rng.ExpFloat64() * float64(e))
+}
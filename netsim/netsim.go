@@ -0,0 +1,219 @@
+// Package netsim simulates the network between the Q1 clients and server. A
+// Network delivers every message after a delay drawn from its link's
+// latency distribution and jitter, may deliver it twice, and lets messages
+// overtake each other within a reordering window.
+package netsim
+
+import (
+	"container/heap"
+	"sync"
+	"time"
+
+	"github.com/Jashveragiwala/Lamport-and-Vector-Clocks/sim"
+)
+
+// Rand is the source of randomness for latencies, jitter and duplication.
+// *rand.Rand satisfies it.
+type Rand interface {
+	Float64() float64
+	NormFloat64() float64
+	ExpFloat64() float64
+}
+
+// LinkConfig describes the behaviour of a directed link. The zero value is a
+// FIFO link that delivers immediately.
+type LinkConfig struct {
+	Latency   Distribution  // Delay before delivery, zero if nil
+	Jitter    time.Duration // Added to every delay, uniformly from [-Jitter, Jitter]
+	Reorder   time.Duration // How much earlier than a previous message a message may be delivered
+	Duplicate float64       // Probability that a message is delivered twice
+}
+
+// Link identifies a directed link between two processes.
+type Link struct {
+	From int
+	To   int
+}
+
+// Network delivers messages between processes. It is safe for concurrent use.
+type Network struct {
+	mu        sync.Mutex
+	drained   *sync.Cond
+	scheduler sim.Scheduler
+	rng       Rand
+	config    LinkConfig
+	configs   map[Link]LinkConfig
+	links     map[Link]*link
+	groups    map[int]int // Group of each process while the network is partitioned, nil otherwise
+	seq       int
+}
+
+type link struct {
+	out      sync.Mutex // Held while delivering so deliveries on a link never overlap
+	pending  packets
+	last     time.Time // Latest delivery time so far
+	inFlight int
+}
+
+type packet struct {
+	due     time.Time
+	seq     int
+	ready   bool
+	deliver func()
+}
+
+// New returns a network whose links all behave as described by config, timed
+// by scheduler. With a simulation engine as the scheduler messages are
+// delivered by the engine's events and Drain must not be used.
+func New(config LinkConfig, scheduler sim.Scheduler, rng Rand) *Network {
+	n := &Network{scheduler: scheduler, rng: rng, config: config, configs: make(map[Link]LinkConfig), links: make(map[Link]*link)}
+	n.drained = sync.NewCond(&n.mu)
+	return n
+}
+
+// SetLink overrides the configuration of the link from one process to another.
+func (n *Network) SetLink(from, to int, config LinkConfig) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.configs[Link{from, to}] = config
+}
+
+// Partition cuts the processes of each group off from those of the other
+// groups until Heal is called. A process in no group is cut off from every
+// other one. Messages sent across the partition are lost, those already on
+// their way still arrive.
+func (n *Network) Partition(groups [][]int) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.groups = make(map[int]int)
+	for i, group := range groups {
+		for _, id := range group {
+			n.groups[id] = i + 1
+		}
+	}
+}
+
+// Heal lets every process reach every other one again.
+func (n *Network) Heal() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.groups = nil
+}
+
+// Reports whether a message from one process to another gets through the
+// partition, if any
+func (n *Network) reachable(from, to int) bool {
+	if n.groups == nil || from == to {
+		return true
+	}
+	group := n.groups[from]
+	return group != 0 && group == n.groups[to]
+}
+
+// Send hands a message from one process to another to the network. deliver
+// is called once for every copy of the message that arrives, never at the
+// same time as another delivery on the same link. A message across a
+// partition is lost.
+func (n *Network) Send(from, to int, deliver func()) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if !n.reachable(from, to) {
+		return
+	}
+	key := Link{from, to}
+	config, ok := n.configs[key]
+	if !ok {
+		config = n.config
+	}
+	l := n.links[key]
+	if l == nil {
+		l = &link{}
+		n.links[key] = l
+	}
+
+	copies := 1
+	if config.Duplicate > 0 && n.rng.Float64() < config.Duplicate {
+		copies = 2
+	}
+	now := n.scheduler.Now()
+	for i := 0; i < copies; i++ {
+		due := now.Add(n.delay(config))
+		// A message may only overtake the messages before it by the reordering window
+		if earliest := l.last.Add(-config.Reorder); due.Before(earliest) {
+			due = earliest
+		}
+		if due.After(l.last) {
+			l.last = due
+		}
+		n.seq++
+		p := &packet{due: due, seq: n.seq, deliver: deliver}
+		heap.Push(&l.pending, p)
+		l.inFlight++
+		n.scheduler.AfterFunc(due.Sub(now), func() { n.arrive(l, p) })
+	}
+}
+
+// Samples the delay of a single copy of a message
+func (n *Network) delay(config LinkConfig) time.Duration {
+	var d time.Duration
+	if config.Latency != nil {
+		d = config.Latency.Sample(n.rng)
+	}
+	if config.Jitter > 0 {
+		d += time.Duration((2*n.rng.Float64() - 1) * float64(config.Jitter))
+	}
+	if d < 0 {
+		d = 0
+	}
+	return d
+}
+
+// Delivers every message at the head of the link that is due, in order
+func (n *Network) arrive(l *link, p *packet) {
+	l.out.Lock()
+	defer l.out.Unlock()
+	n.mu.Lock()
+	p.ready = true
+	var ready []*packet
+	for l.pending.Len() > 0 && l.pending[0].ready {
+		ready = append(ready, heap.Pop(&l.pending).(*packet))
+	}
+	n.mu.Unlock()
+
+	for _, p := range ready {
+		p.deliver()
+	}
+
+	n.mu.Lock()
+	l.inFlight -= len(ready)
+	n.drained.Broadcast()
+	n.mu.Unlock()
+}
+
+// Drain blocks until every message sent from one process to another so far
+// has been delivered.
+func (n *Network) Drain(from, to int) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for l := n.links[Link{from, to}]; l != nil && l.inFlight > 0; {
+		n.drained.Wait()
+	}
+}
+
+type packets []*packet
+
+func (p packets) Len() int { return len(p) }
+func (p packets) Less(i, j int) bool {
+	if !p[i].due.Equal(p[j].due) {
+		return p[i].due.Before(p[j].due)
+	}
+	return p[i].seq < p[j].seq
+}
+func (p packets) Swap(i, j int)       { p[i], p[j] = p[j], p[i] }
+func (p *packets) Push(x interface{}) { *p = append(*p, x.(*packet)) }
+func (p *packets) Pop() interface{} {
+	old := *p
+	x := old[len(old)-1]
+	*p = old[:len(old)-1]
+	return x
+}
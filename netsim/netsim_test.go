@@ -0,0 +1,119 @@
+package netsim
+
+import (
+	"math/rand"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/Jashveragiwala/Lamport-and-Vector-Clocks/sim"
+)
+
+// Records the number and virtual arrival time of every copy of the messages
+// it sends that arrives
+type recorder struct {
+	engine   *sim.Engine
+	received []int
+	arrivals []time.Duration
+}
+
+func (r *recorder) send(n *Network, from, to, count int) {
+	for i := 1; i <= count; i++ {
+		i := i
+		n.Send(from, to, func() {
+			r.received = append(r.received, i)
+			r.arrivals = append(r.arrivals, r.engine.Elapsed())
+		})
+	}
+}
+
+func TestNetworkKeepsLinksFIFOWithoutReordering(t *testing.T) {
+	engine := sim.NewEngine()
+	n := New(LinkConfig{Latency: Uniform(0, 100*time.Millisecond)}, engine, rand.New(rand.NewSource(1)))
+	r := &recorder{engine: engine}
+	r.send(n, 1, 0, 50)
+	engine.Run(0)
+	want := make([]int, 50)
+	for i := range want {
+		want[i] = i + 1
+	}
+	if !slices.Equal(r.received, want) {
+		t.Errorf("received %v, want every message in order", r.received)
+	}
+}
+
+func TestNetworkReordersWithinTheWindow(t *testing.T) {
+	engine := sim.NewEngine()
+	n := New(LinkConfig{Latency: Uniform(0, 100*time.Millisecond), Reorder: 100 * time.Millisecond}, engine, rand.New(rand.NewSource(1)))
+	r := &recorder{engine: engine}
+	r.send(n, 1, 0, 50)
+	engine.Run(0)
+	if len(r.received) != 50 {
+		t.Fatalf("received %d messages, want 50", len(r.received))
+	}
+	if slices.IsSorted(r.received) {
+		t.Errorf("received every message in order with a reordering window")
+	}
+}
+
+func TestParseOverridesLinks(t *testing.T) {
+	engine := sim.NewEngine()
+	n, err := Parse("latency=constant:10ms;2->0:latency=constant:50ms", engine, rand.New(rand.NewSource(1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fast, slow := &recorder{engine: engine}, &recorder{engine: engine}
+	fast.send(n, 1, 0, 1)
+	slow.send(n, 2, 0, 1)
+	engine.Run(0)
+	if !slices.Equal(fast.arrivals, []time.Duration{10 * time.Millisecond}) {
+		t.Errorf("1->0 delivered at %v, want [10ms]", fast.arrivals)
+	}
+	if !slices.Equal(slow.arrivals, []time.Duration{50 * time.Millisecond}) {
+		t.Errorf("2->0 delivered at %v, want [50ms]", slow.arrivals)
+	}
+}
+
+func TestParseDuplicates(t *testing.T) {
+	engine := sim.NewEngine()
+	n, err := Parse("duplicate=1", engine, rand.New(rand.NewSource(1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &recorder{engine: engine}
+	r.send(n, 1, 0, 2)
+	engine.Run(0)
+	if !slices.Equal(r.received, []int{1, 1, 2, 2}) {
+		t.Errorf("received %v, want [1 1 2 2]", r.received)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, spec := range []string{"latency=sometimes", "latency=constant:soon", "duplicate=2", "speed=fast", "x->0:latency=constant:1ms"} {
+		if _, err := Parse(spec, sim.NewEngine(), rand.New(rand.NewSource(1))); err == nil {
+			t.Errorf("Parse(%q) succeeded, want an error", spec)
+		}
+	}
+}
+
+func TestPartitionLosesMessagesUntilHealed(t *testing.T) {
+	engine := sim.NewEngine()
+	n := New(LinkConfig{Latency: Constant(time.Millisecond)}, engine, rand.New(rand.NewSource(1)))
+	n.Partition([][]int{{1, 2}, {3}})
+	within, across, isolated := &recorder{engine: engine}, &recorder{engine: engine}, &recorder{engine: engine}
+	within.send(n, 1, 2, 1)
+	across.send(n, 1, 3, 1)
+	isolated.send(n, 4, 1, 1)
+	n.Heal()
+	across.send(n, 3, 1, 1)
+	engine.Run(0)
+	if len(within.received) != 1 {
+		t.Errorf("message within a group was lost")
+	}
+	if !slices.Equal(across.received, []int{1}) {
+		t.Errorf("received %v across the partition, want only the message sent after the heal", across.received)
+	}
+	if len(isolated.received) != 0 {
+		t.Errorf("message from a process in no group got through")
+	}
+}
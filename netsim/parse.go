@@ -0,0 +1,118 @@
+package netsim
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Jashveragiwala/Lamport-and-Vector-Clocks/sim"
+)
+
+// Usage describes the network specifications accepted by Parse.
+const Usage = `simulated network, as ";"-separated link settings where 0 is the server:
+  [FROM->TO:]SETTING,...   applies to the link FROM->TO, or to every link without a prefix
+settings:
+  latency=constant:D | uniform:MIN:MAX | normal:MEAN:STDDEV | exponential:MEAN
+  jitter=D        add a uniform delay from [-D, D]
+  reorder=D       let a message overtake messages sent up to D before it
+  duplicate=P     deliver a message twice with probability P
+for example "latency=uniform:10ms:50ms,reorder=30ms;2->0:latency=constant:500ms,duplicate=0.2"`
+
+// Parse builds a network from a specification as described by Usage. An
+// empty specification gives a network that delivers immediately and in order.
+func Parse(spec string, scheduler sim.Scheduler, rng Rand) (*Network, error) {
+	n := New(LinkConfig{}, scheduler, rng)
+	for _, part := range strings.Split(spec, ";") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		from, to, settings, err := parseLink(part)
+		if err != nil {
+			return nil, err
+		}
+		config := LinkConfig{}
+		if from >= 0 {
+			// Links start from the settings for every link given before them
+			config = n.config
+		}
+		if err := parseSettings(settings, &config); err != nil {
+			return nil, err
+		}
+		if from < 0 {
+			n.config = config
+		} else {
+			n.SetLink(from, to, config)
+		}
+	}
+	return n, nil
+}
+
+// Splits an optional FROM->TO: prefix off a link setting, with from -1 if
+// there is none
+func parseLink(part string) (from, to int, settings string, err error) {
+	prefix, rest, ok := strings.Cut(part, ":")
+	ends := strings.Split(prefix, "->")
+	if !ok || len(ends) != 2 {
+		return -1, -1, part, nil
+	}
+	from, err1 := strconv.Atoi(strings.TrimSpace(ends[0]))
+	to, err2 := strconv.Atoi(strings.TrimSpace(ends[1]))
+	if err1 != nil || err2 != nil || from < 0 || to < 0 {
+		return 0, 0, "", fmt.Errorf("invalid link %q", prefix)
+	}
+	return from, to, rest, nil
+}
+
+func parseSettings(settings string, config *LinkConfig) error {
+	for _, setting := range strings.Split(settings, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(setting), "=")
+		if !ok {
+			return fmt.Errorf("expected NAME=VALUE, got %q", setting)
+		}
+		var err error
+		switch name {
+		case "latency":
+			config.Latency, err = parseDistribution(value)
+		case "jitter":
+			config.Jitter, err = time.ParseDuration(value)
+		case "reorder":
+			config.Reorder, err = time.ParseDuration(value)
+		case "duplicate":
+			config.Duplicate, err = strconv.ParseFloat(value, 64)
+			if err == nil && (config.Duplicate < 0 || config.Duplicate > 1) {
+				err = fmt.Errorf("probability out of range")
+			}
+		default:
+			return fmt.Errorf("unknown network setting %q", name)
+		}
+		if err != nil {
+			return fmt.Errorf("invalid %s %q: %v", name, value, err)
+		}
+	}
+	return nil
+}
+
+func parseDistribution(value string) (Distribution, error) {
+	fields := strings.Split(value, ":")
+	durations := make([]time.Duration, len(fields)-1)
+	for i, field := range fields[1:] {
+		d, err := time.ParseDuration(field)
+		if err != nil {
+			return nil, err
+		}
+		durations[i] = d
+	}
+	switch {
+	case fields[0] == "constant" && len(durations) == 1:
+		return Constant(durations[0]), nil
+	case fields[0] == "uniform" && len(durations) == 2:
+		return Uniform(durations[0], durations[1]), nil
+	case fields[0] == "normal" && len(durations) == 2:
+		return Normal(durations[0], durations[1]), nil
+	case fields[0] == "exponential" && len(durations) == 1:
+		return Exponential(durations[0]), nil
+	}
+	return nil, fmt.Errorf("unknown distribution")
+}
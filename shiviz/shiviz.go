@@ -0,0 +1,127 @@
+// Package shiviz writes logs that can be loaded into the ShiViz visualiser
+// (https://bestchai.bitbucket.io/shiviz/). Every event takes two lines: the
+// host name followed by its vector clock as a JSON object keyed by host, and
+// then the event description. Paste the log into ShiViz together with Regexp.
+package shiviz
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Regexp is the ShiViz log parsing expression matching the logs written here.
+const Regexp = `(?<host>\S*) (?<clock>{.*})\n(?<event>.*)`
+
+// Logger collects events and writes them when closed, ordered so that every
+// event comes after the events that happened before it. It is safe for
+// concurrent use. A nil Logger discards events, so programs can log
+// unconditionally.
+type Logger struct {
+	mu     sync.Mutex
+	hosts  []string
+	events []event
+	w      io.Writer
+	closer io.Closer
+	closed bool
+}
+
+type event struct {
+	host        int
+	vector      []int
+	description string
+	weight      int
+}
+
+// NewLogger returns a Logger writing to w. hosts names the process at every
+// index of the vector timestamps; names must not contain white space.
+func NewLogger(w io.Writer, hosts []string) *Logger {
+	return &Logger{hosts: hosts, w: w}
+}
+
+// Create creates or truncates the file at path and returns a Logger for it.
+func Create(path string, hosts []string) (*Logger, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	l := NewLogger(f, hosts)
+	l.closer = f
+	return l, nil
+}
+
+// Log records an event of host, the index of the process in the vector
+// timestamps, stamped with vector.
+func (l *Logger) Log(host int, vector []int, description string) {
+	if l == nil {
+		return
+	}
+	weight := 0
+	for _, v := range vector {
+		weight += v
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.events = append(l.events, event{host, append([]int(nil), vector...), strings.ReplaceAll(description, "\n", " "), weight})
+}
+
+// Close writes the collected events and closes the underlying file, if any.
+// Events are sorted by the sum of their vector entries, which always places
+// an event after everything that happened before it.
+func (l *Logger) Close() error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return nil
+	}
+	l.closed = true
+
+	sort.SliceStable(l.events, func(i, j int) bool { return l.events[i].weight < l.events[j].weight })
+	buf := bufio.NewWriter(l.w)
+	var err error
+	for _, e := range l.events {
+		vc := make(map[string]int)
+		for i, v := range e.vector {
+			if v > 0 && i < len(l.hosts) {
+				vc[l.hosts[i]] = v
+			}
+		}
+		clock, _ := json.Marshal(vc)
+		if _, err = fmt.Fprintf(buf, "%s %s\n%s\n", l.hosts[e.host], clock, e.description); err != nil {
+			break
+		}
+	}
+	if ferr := buf.Flush(); err == nil {
+		err = ferr
+	}
+	if l.closer != nil {
+		if cerr := l.closer.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// CloseOnInterrupt closes the log and exits when the program is interrupted,
+// so runs that never end on their own still produce a log.
+func (l *Logger) CloseOnInterrupt() {
+	if l == nil {
+		return
+	}
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	go func() {
+		<-interrupt
+		l.Close()
+		os.Exit(130)
+	}()
+}
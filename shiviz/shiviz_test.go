@@ -0,0 +1,50 @@
+package shiviz
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestLoggerWritesEventsAfterTheirPast(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewLogger(&buf, []string{"Server", "Client1"})
+	// Logged out of order, as concurrent goroutines may do
+	l.Log(0, []int{1, 1}, "Server receives\nMessage 1")
+	l.Log(1, []int{0, 1}, "Client1 sends Message 1")
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+	want := "Client1 {\"Client1\":1}\nClient1 sends Message 1\n" +
+		"Server {\"Client1\":1,\"Server\":1}\nServer receives Message 1\n"
+	if got := buf.String(); got != want {
+		t.Errorf("log =\n%s\nwant\n%s", got, want)
+	}
+
+	// Every event must match the expression ShiViz is given, Go's named groups aside
+	re := regexp.MustCompile(strings.ReplaceAll(Regexp, "(?<", "(?P<"))
+	if matches := re.FindAllString(buf.String(), -1); len(matches) != 2 {
+		t.Errorf("Regexp matches %d events, want 2", len(matches))
+	}
+}
+
+func TestLoggerClosesOnce(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewLogger(&buf, []string{"Server"})
+	l.Log(0, []int{1}, "Server starts")
+	l.Close()
+	l.Log(0, []int{2}, "Server stops")
+	l.Close()
+	if got := strings.Count(buf.String(), "\n"); got != 2 {
+		t.Errorf("log has %d lines after closing twice, want 2", got)
+	}
+}
+
+func TestNilLoggerDiscardsEvents(t *testing.T) {
+	var l *Logger
+	l.Log(0, []int{1}, "Server starts")
+	if err := l.Close(); err != nil {
+		t.Errorf("Close() = %v, want nil", err)
+	}
+}
@@ -0,0 +1,118 @@
+// Package sim schedules the work of the simulations, either in real time or
+// in virtual time with a discrete-event engine. An engine runs one event at a
+// time, so a run whose random decisions come from one seeded source (see
+// package chance) always does the same thing, and it finishes as soon as its
+// last event has run.
+package sim
+
+import (
+	"container/heap"
+	"time"
+)
+
+// Scheduler runs functions after a delay.
+type Scheduler interface {
+	Now() time.Time
+	AfterFunc(d time.Duration, f func())
+}
+
+type realTime struct{}
+
+// RealTime returns a scheduler that uses the wall clock and runs every
+// function in its own goroutine once its timer fires.
+func RealTime() Scheduler {
+	return realTime{}
+}
+
+func (realTime) Now() time.Time {
+	return time.Now()
+}
+
+func (realTime) AfterFunc(d time.Duration, f func()) {
+	time.AfterFunc(d, f)
+}
+
+// Epoch is the virtual time at which every simulation starts.
+var Epoch = time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// Engine is a discrete-event scheduler with a virtual clock. Events run one
+// at a time in the order of their virtual time, and events due at the same
+// time run in the order they were scheduled. It is not safe for concurrent
+// use: functions scheduled on an engine must only be called by its events.
+type Engine struct {
+	now     time.Time
+	events  events
+	seq     int
+	stopped bool
+}
+
+type event struct {
+	at  time.Time
+	seq int
+	f   func()
+}
+
+// NewEngine returns an engine at Epoch.
+func NewEngine() *Engine {
+	return &Engine{now: Epoch}
+}
+
+// Now returns the virtual time.
+func (e *Engine) Now() time.Time {
+	return e.now
+}
+
+// Elapsed returns the virtual time since Epoch.
+func (e *Engine) Elapsed() time.Duration {
+	return e.now.Sub(Epoch)
+}
+
+// AfterFunc schedules f to run once the virtual clock has advanced by d.
+func (e *Engine) AfterFunc(d time.Duration, f func()) {
+	if d < 0 {
+		d = 0
+	}
+	e.seq++
+	heap.Push(&e.events, event{e.now.Add(d), e.seq, f})
+}
+
+// Stop makes Run return once the current event has finished.
+func (e *Engine) Stop() {
+	e.stopped = true
+}
+
+// Run runs events until none are left, Stop is called or the next event is
+// due more than limit after Epoch, where a limit of 0 means no limit. It
+// returns the number of events run.
+func (e *Engine) Run(limit time.Duration) int {
+	count := 0
+	e.stopped = false
+	for !e.stopped && e.events.Len() > 0 {
+		if limit > 0 && e.events[0].at.Sub(Epoch) > limit {
+			break
+		}
+		next := heap.Pop(&e.events).(event)
+		e.now = next.at
+		next.f()
+		count++
+	}
+	return count
+}
+
+type events []event
+
+func (q events) Len() int { return len(q) }
+func (q events) Less(i, j int) bool {
+	if !q[i].at.Equal(q[j].at) {
+		return q[i].at.Before(q[j].at)
+	}
+	return q[i].seq < q[j].seq
+}
+func (q events) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *events) Push(x interface{}) { *q = append(*q, x.(event)) }
+func (q *events) Pop() interface{} {
+	old := *q
+	x := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return x
+}
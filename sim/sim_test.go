@@ -0,0 +1,118 @@
+package sim
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/Jashveragiwala/Lamport-and-Vector-Clocks/chance"
+)
+
+func TestEngineRunsEventsInTimeOrder(t *testing.T) {
+	engine := NewEngine()
+	var order []string
+	at := func(name string) func() {
+		return func() { order = append(order, fmt.Sprintf("%s@%v", name, engine.Elapsed())) }
+	}
+	engine.AfterFunc(30*time.Millisecond, at("c"))
+	engine.AfterFunc(10*time.Millisecond, func() {
+		at("a")()
+		// Scheduled from an event, so it is due relative to the virtual time of that event
+		engine.AfterFunc(5*time.Millisecond, at("b"))
+	})
+	engine.AfterFunc(-time.Second, at("now"))
+
+	if events := engine.Run(0); events != 4 {
+		t.Errorf("Run = %d events, want 4", events)
+	}
+	want := []string{"now@0s", "a@10ms", "b@15ms", "c@30ms"}
+	if !slices.Equal(order, want) {
+		t.Errorf("events ran as %v, want %v", order, want)
+	}
+	if engine.Now() != Epoch.Add(30*time.Millisecond) {
+		t.Errorf("Now = %v, want 30ms after Epoch", engine.Now())
+	}
+}
+
+func TestEngineRunsEventsAtTheSameTimeInScheduleOrder(t *testing.T) {
+	engine := NewEngine()
+	var order []int
+	for i := 0; i < 20; i++ {
+		i := i
+		engine.AfterFunc(time.Second, func() { order = append(order, i) })
+	}
+	engine.AfterFunc(0, func() {
+		// Due at the same time as the others, but scheduled after them
+		engine.AfterFunc(time.Second, func() { order = append(order, 20) })
+	})
+	engine.Run(0)
+
+	for i, n := range order {
+		if n != i {
+			t.Fatalf("events ran as %v, want them in the order they were scheduled", order)
+		}
+	}
+	if len(order) != 21 {
+		t.Errorf("%d events ran, want 21", len(order))
+	}
+}
+
+// Runs processes that wake each other up after random delays and returns the
+// order in which they ran
+func randomRun(seed int64) []string {
+	engine := NewEngine()
+	random := chance.New(seed)
+	var order []string
+	var wake func(id, hops int)
+	wake = func(id, hops int) {
+		order = append(order, fmt.Sprintf("%d@%v", id, engine.Elapsed()))
+		if hops == 0 {
+			return
+		}
+		for i := 0; i < 2; i++ {
+			next := random.Intn("process", 5)
+			engine.AfterFunc(time.Duration(random.Intn("delay", 10))*time.Millisecond, func() { wake(next, hops-1) })
+		}
+	}
+	for id := 0; id < 5; id++ {
+		id := id
+		engine.AfterFunc(0, func() { wake(id, 4) })
+	}
+	engine.Run(0)
+	return order
+}
+
+func TestEngineRepeatsARunWithTheSameSeed(t *testing.T) {
+	first := randomRun(7)
+	for i := 0; i < 5; i++ {
+		if again := randomRun(7); !slices.Equal(again, first) {
+			t.Fatalf("run %d with the same seed ran\n%v\nwant\n%v", i+2, again, first)
+		}
+	}
+	if other := randomRun(8); slices.Equal(other, first) {
+		t.Errorf("runs with seeds 7 and 8 ran the same events")
+	}
+}
+
+func TestEngineStopsAtTheLimitAndOnStop(t *testing.T) {
+	engine := NewEngine()
+	ran := 0
+	for i := 1; i <= 5; i++ {
+		engine.AfterFunc(time.Duration(i)*time.Second, func() { ran++ })
+	}
+	if events := engine.Run(3 * time.Second); events != 3 || ran != 3 {
+		t.Errorf("Run(3s) ran %d events, want 3", ran)
+	}
+	if engine.Elapsed() != 3*time.Second {
+		t.Errorf("Elapsed = %v after Run(3s), want 3s", engine.Elapsed())
+	}
+
+	engine.AfterFunc(0, engine.Stop)
+	if events := engine.Run(0); events != 1 {
+		t.Errorf("Run ran %d events after Stop, want 1", events)
+	}
+	if events := engine.Run(0); events != 2 || ran != 5 {
+		t.Errorf("Run after a stop ran %d events, want the 2 left", events)
+	}
+}
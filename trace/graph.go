@@ -0,0 +1,200 @@
+package trace
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/Jashveragiwala/Lamport-and-Vector-Clocks/clock"
+)
+
+// Graph is the happened-before graph of a trace. Trace events are identified
+// by their index in the trace. Events of a process with the same entry for
+// that process in their vector timestamps are one logical event: the server
+// records one broadcast per receiving client for a single send event. The
+// graph links every logical event to the next one of its process and every
+// send to the matching receives.
+type Graph struct {
+	Events  []Event
+	node    []int   // logical event of every trace event
+	members [][]int // trace events of every logical event
+	succ    [][]int
+	pred    [][]int
+	sends   [][2]int // trace events of every send and its receive
+}
+
+// NewGraph builds the happened-before graph of events.
+func NewGraph(events []Event) *Graph {
+	g := &Graph{Events: events, node: make([]int, len(events))}
+
+	// Logical events in process order, by the process's own vector entry
+	type point struct{ process, time int }
+	nodes := make(map[point]int)
+	byProcess := make(map[int][]int)
+	for i, e := range events {
+		p := point{e.Process, entry(e, e.Process)}
+		n, ok := nodes[p]
+		if !ok {
+			n = len(g.members)
+			nodes[p] = n
+			g.members = append(g.members, nil)
+			byProcess[e.Process] = append(byProcess[e.Process], n)
+		}
+		g.node[i] = n
+		g.members[n] = append(g.members[n], i)
+	}
+	g.succ = make([][]int, len(g.members))
+	g.pred = make([][]int, len(g.members))
+	for process, order := range byProcess {
+		sort.Slice(order, func(a, b int) bool {
+			return entry(events[g.members[order[a]][0]], process) < entry(events[g.members[order[b]][0]], process)
+		})
+		for i := 1; i < len(order); i++ {
+			g.addEdge(order[i-1], order[i])
+		}
+	}
+
+	// Message edges from every send to the receives of the same message
+	type link struct{ from, to, sender, messageID int }
+	sends := make(map[link][]int)
+	for i, e := range events {
+		if e.Type == ClientSend || e.Type == ServerBroadcast {
+			key := link{e.Process, e.Peer, e.Sender, e.MessageID}
+			sends[key] = append(sends[key], i)
+		}
+	}
+	for i, e := range events {
+		if e.Type == ServerReceive || e.Type == ClientReceive {
+			for _, from := range sends[link{e.Peer, e.Process, e.Sender, e.MessageID}] {
+				g.addEdge(g.node[from], g.node[i])
+				g.sends = append(g.sends, [2]int{from, i})
+			}
+		}
+	}
+	return g
+}
+
+func (g *Graph) addEdge(from, to int) {
+	g.succ[from] = append(g.succ[from], to)
+	g.pred[to] = append(g.pred[to], from)
+}
+
+func entry(e Event, process int) int {
+	if process < len(e.Vector) {
+		return e.Vector[process]
+	}
+	return 0
+}
+
+// Messages returns the pairs of trace events of every delivered message: the
+// send or broadcast and the matching receive.
+func (g *Graph) Messages() [][2]int {
+	return g.sends
+}
+
+// Lamport returns a Lamport timestamp for every trace event, the length of
+// the longest chain of logical events leading to it. Trace events of the same
+// logical event share a timestamp.
+func (g *Graph) Lamport() []int {
+	times := make([]int, len(g.members))
+	waiting := make([]int, len(g.members))
+	var ready []int
+	for n := range g.members {
+		waiting[n] = len(g.pred[n])
+		if waiting[n] == 0 {
+			ready = append(ready, n)
+		}
+	}
+	for len(ready) > 0 {
+		n := ready[0]
+		ready = ready[1:]
+		times[n]++
+		for _, next := range g.succ[n] {
+			if times[n] > times[next] {
+				times[next] = times[n]
+			}
+			if waiting[next]--; waiting[next] == 0 {
+				ready = append(ready, next)
+			}
+		}
+	}
+	lamport := make([]int, len(g.Events))
+	for i, n := range g.node {
+		lamport[i] = times[n]
+	}
+	return lamport
+}
+
+// HappenedBefore reports whether event a causally precedes event b.
+func (g *Graph) HappenedBefore(a, b int) bool {
+	return g.reachable(g.node[a], g.succ)[g.node[b]]
+}
+
+// Past returns the events that causally precede x, in trace order.
+func (g *Graph) Past(x int) []int {
+	return g.events(g.reachable(g.node[x], g.pred))
+}
+
+// Future returns the events that x causally precedes, in trace order.
+func (g *Graph) Future(x int) []int {
+	return g.events(g.reachable(g.node[x], g.succ))
+}
+
+// Concurrent returns the events that neither precede nor follow x, in trace
+// order. Other trace events of the same logical event are left out.
+func (g *Graph) Concurrent(x int) []int {
+	past, future := g.reachable(g.node[x], g.pred), g.reachable(g.node[x], g.succ)
+	var concurrent []int
+	for i, n := range g.node {
+		if n != g.node[x] && !past[n] && !future[n] {
+			concurrent = append(concurrent, i)
+		}
+	}
+	return concurrent
+}
+
+func (g *Graph) reachable(from int, edges [][]int) []bool {
+	seen := make([]bool, len(g.members))
+	stack := []int{from}
+	for len(stack) > 0 {
+		n := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		for _, next := range edges[n] {
+			if !seen[next] {
+				seen[next] = true
+				stack = append(stack, next)
+			}
+		}
+	}
+	return seen
+}
+
+// Trace events of the logical events in the set, in trace order
+func (g *Graph) events(set []bool) []int {
+	var list []int
+	for i, n := range g.node {
+		if set[n] {
+			list = append(list, i)
+		}
+	}
+	return list
+}
+
+// Verify checks the vector timestamps against the graph and describes every
+// pair of logical events for which they disagree about happened-before.
+func (g *Graph) Verify() []string {
+	var problems []string
+	for a := range g.members {
+		future := g.reachable(a, g.succ)
+		for b := range g.members {
+			if a == b {
+				continue
+			}
+			ea, eb := g.members[a][0], g.members[b][0]
+			byVector := clock.Compare(g.Events[ea].Vector, g.Events[eb].Vector) == clock.Before
+			if future[b] != byVector {
+				problems = append(problems, fmt.Sprintf("#%d -> #%d: graph says %t, vector timestamps %v and %v say %t", ea+1, eb+1, future[b], g.Events[ea].Vector, g.Events[eb].Vector, byVector))
+			}
+		}
+	}
+	return problems
+}
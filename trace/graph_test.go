@@ -0,0 +1,130 @@
+package trace
+
+import (
+	"slices"
+	"testing"
+)
+
+// Client 1 and Client 2 each send a message at the same time. The server
+// broadcasts Client 1's message to Client 2 and then receives Client 2's.
+func sampleTrace() []Event {
+	return []Event{
+		{Process: 1, Peer: 0, Sender: 1, MessageID: 1, Vector: []int{0, 1, 0}, Type: ClientSend},
+		{Process: 2, Peer: 0, Sender: 2, MessageID: 1, Vector: []int{0, 0, 1}, Type: ClientSend},
+		{Process: 0, Peer: 1, Sender: 1, MessageID: 1, Vector: []int{1, 1, 0}, Type: ServerReceive},
+		{Process: 0, Peer: 2, Sender: 1, MessageID: 1, Vector: []int{2, 1, 0}, Type: ServerBroadcast},
+		{Process: 2, Peer: 0, Sender: 1, MessageID: 1, Vector: []int{2, 1, 2}, Type: ClientReceive},
+		{Process: 0, Peer: 2, Sender: 2, MessageID: 1, Vector: []int{3, 1, 1}, Type: ServerReceive},
+	}
+}
+
+func TestGraphHappenedBefore(t *testing.T) {
+	g := NewGraph(sampleTrace())
+	tests := []struct {
+		name string
+		a, b int
+		want bool
+	}{
+		{"message", 0, 2, true},
+		{"process order", 2, 3, true},
+		{"transitive over two messages", 0, 4, true},
+		{"process order then message", 1, 4, true},
+		{"not backwards", 4, 0, false},
+		{"concurrent sends", 0, 1, false},
+		{"concurrent sends reversed", 1, 0, false},
+		{"concurrent receives", 4, 5, false},
+		{"not reflexive", 3, 3, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := g.HappenedBefore(tt.a, tt.b); got != tt.want {
+				t.Errorf("HappenedBefore(%d, %d) = %t, want %t", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGraphPastFutureAndConcurrent(t *testing.T) {
+	g := NewGraph(sampleTrace())
+	if got := g.Past(4); !slices.Equal(got, []int{0, 1, 2, 3}) {
+		t.Errorf("Past(4) = %v, want [0 1 2 3]", got)
+	}
+	if got := g.Future(0); !slices.Equal(got, []int{2, 3, 4, 5}) {
+		t.Errorf("Future(0) = %v, want [2 3 4 5]", got)
+	}
+	if got := g.Concurrent(1); !slices.Equal(got, []int{0, 2, 3}) {
+		t.Errorf("Concurrent(1) = %v, want [0 2 3]", got)
+	}
+	if got := g.Concurrent(4); !slices.Equal(got, []int{5}) {
+		t.Errorf("Concurrent(4) = %v, want [5]", got)
+	}
+}
+
+func TestGraphMergesBroadcastsOfOneSend(t *testing.T) {
+	// The server broadcasts one send to both clients, so both broadcasts carry the same server entry
+	events := []Event{
+		{Process: 1, Peer: 0, Sender: 1, MessageID: 1, Vector: []int{0, 1, 0}, Type: ClientSend},
+		{Process: 0, Peer: 1, Sender: 1, MessageID: 1, Vector: []int{1, 1, 0}, Type: ServerReceive},
+		{Process: 0, Peer: 1, Sender: 1, MessageID: 1, Vector: []int{2, 1, 0}, Type: ServerBroadcast},
+		{Process: 0, Peer: 2, Sender: 1, MessageID: 1, Vector: []int{2, 1, 0}, Type: ServerBroadcast},
+		{Process: 2, Peer: 0, Sender: 1, MessageID: 1, Vector: []int{2, 1, 1}, Type: ClientReceive},
+	}
+	g := NewGraph(events)
+	if g.HappenedBefore(2, 3) || g.HappenedBefore(3, 2) {
+		t.Errorf("broadcasts of one send are ordered")
+	}
+	if !g.HappenedBefore(2, 4) {
+		t.Errorf("the broadcast to Client 1 does not precede Client 2's receive of the same send")
+	}
+	if got := g.Concurrent(2); len(got) != 0 {
+		t.Errorf("Concurrent(2) = %v, want the other broadcast left out", got)
+	}
+	if problems := g.Verify(); len(problems) != 0 {
+		t.Errorf("Verify() = %v, want no problems", problems)
+	}
+}
+
+func TestGraphVerify(t *testing.T) {
+	if problems := NewGraph(sampleTrace()).Verify(); len(problems) != 0 {
+		t.Errorf("Verify() = %v, want no problems", problems)
+	}
+	events := sampleTrace()
+	// Client 2's receive forgets Client 1's entry, so its timestamp no longer follows Client 1's send
+	events[4].Vector = []int{2, 0, 2}
+	if problems := NewGraph(events).Verify(); len(problems) == 0 {
+		t.Errorf("Verify() found no problems with a receive that lost an entry")
+	}
+}
+
+func TestGraphMessagesAndLamport(t *testing.T) {
+	g := NewGraph(sampleTrace())
+	if got := g.Messages(); !slices.Equal(got, [][2]int{{0, 2}, {3, 4}, {1, 5}}) {
+		t.Errorf("Messages() = %v, want [[0 2] [3 4] [1 5]]", got)
+	}
+	if got := g.Lamport(); !slices.Equal(got, []int{1, 1, 2, 3, 4, 4}) {
+		t.Errorf("Lamport() = %v, want [1 1 2 3 4 4]", got)
+	}
+}
+
+func TestGraphDropHasNoReceive(t *testing.T) {
+	// The server drops Client 1's message instead of broadcasting it, and Client 2 never receives it
+	events := []Event{
+		{Process: 1, Peer: 0, Sender: 1, MessageID: 1, Vector: []int{0, 1, 0}, Type: ClientSend},
+		{Process: 0, Peer: 1, Sender: 1, MessageID: 1, Vector: []int{1, 1, 0}, Type: ServerReceive},
+		{Process: 0, Peer: 2, Sender: 1, MessageID: 1, Vector: []int{2, 1, 0}, Type: ServerDrop},
+		{Process: 2, Peer: 0, Sender: 2, MessageID: 1, Vector: []int{0, 0, 1}, Type: ClientSend},
+	}
+	g := NewGraph(events)
+	if got := g.Messages(); !slices.Equal(got, [][2]int{{0, 1}}) {
+		t.Errorf("Messages() = %v, want only the send to the server", got)
+	}
+	if !g.HappenedBefore(0, 2) {
+		t.Errorf("the client's send does not precede the drop")
+	}
+	if g.HappenedBefore(2, 3) || g.HappenedBefore(3, 2) {
+		t.Errorf("the drop is ordered with Client 2's send")
+	}
+	if got := g.Future(2); len(got) != 0 {
+		t.Errorf("Future of the drop = %v, want nothing", got)
+	}
+}
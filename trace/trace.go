@@ -0,0 +1,119 @@
+// Package trace records the events of a Q1_3 run as JSON Lines, one event
+// per line, so runs can be analysed after the fact.
+package trace
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// Event types recorded by Q1_3.
+const (
+	ClientSend      = "CLIENT_SEND"
+	ServerReceive   = "SERVER_RECEIVE"
+	ServerBroadcast = "SERVER_BROADCAST"
+	ClientReceive   = "CLIENT_RECEIVE"
+	ServerDrop      = "SERVER_DROP"
+)
+
+// Event is a single send or receive event. Process is the process the event
+// happened at (0 is the server) and Peer the process on the other end of the
+// message. Sender and MessageID identify the client message the event is
+// about, since message IDs are only unique per sender. Violation marks a
+// receive that was a causality violation.
+type Event struct {
+	Process   int       `json:"process"`
+	Peer      int       `json:"peer"`
+	Sender    int       `json:"sender"`
+	MessageID int       `json:"message_id"`
+	Vector    []int     `json:"vector"`
+	Type      string    `json:"type"`
+	Time      time.Time `json:"time"`
+	Violation bool      `json:"violation,omitempty"`
+}
+
+// String describes the event in the words used by the Q1_3 output.
+func (e Event) String() string {
+	switch e.Type {
+	case ClientSend:
+		return fmt.Sprintf("Client %d sends Message %d to Server", e.Process, e.MessageID)
+	case ServerReceive:
+		return fmt.Sprintf("Server receives Message %d from Client %d", e.MessageID, e.Sender)
+	case ServerBroadcast:
+		return fmt.Sprintf("Server broadcasts Message %d from Client %d to Client %d", e.MessageID, e.Sender, e.Peer)
+	case ClientReceive:
+		return fmt.Sprintf("Client %d receives Message %d from Client %d", e.Process, e.MessageID, e.Sender)
+	case ServerDrop:
+		return fmt.Sprintf("Server drops Message %d from Client %d for Client %d", e.MessageID, e.Sender, e.Peer)
+	}
+	return fmt.Sprintf("%s at process %d", e.Type, e.Process)
+}
+
+// Writer writes events to a JSON Lines stream.
+type Writer struct {
+	buf    *bufio.Writer
+	enc    *json.Encoder
+	closer io.Closer
+}
+
+// NewWriter returns a Writer writing to w.
+func NewWriter(w io.Writer) *Writer {
+	buf := bufio.NewWriter(w)
+	return &Writer{buf: buf, enc: json.NewEncoder(buf)}
+}
+
+// Create creates or truncates the file at path and returns a Writer for it.
+func Create(path string) (*Writer, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	w := NewWriter(f)
+	w.closer = f
+	return w, nil
+}
+
+// Write appends an event to the trace.
+func (w *Writer) Write(e Event) error {
+	return w.enc.Encode(e)
+}
+
+// Close flushes the trace and closes the underlying file, if any.
+func (w *Writer) Close() error {
+	err := w.buf.Flush()
+	if w.closer != nil {
+		if cerr := w.closer.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// Read reads every event of a JSON Lines trace.
+func Read(r io.Reader) ([]Event, error) {
+	var events []Event
+	dec := json.NewDecoder(r)
+	for {
+		var e Event
+		if err := dec.Decode(&e); err == io.EOF {
+			return events, nil
+		} else if err != nil {
+			return events, fmt.Errorf("event %d: %w", len(events)+1, err)
+		}
+		events = append(events, e)
+	}
+}
+
+// ReadFile reads every event of the trace file at path.
+func ReadFile(path string) ([]Event, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Read(f)
+}
@@ -0,0 +1,43 @@
+package trace
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteThenRead(t *testing.T) {
+	events := []Event{
+		{Process: 1, Peer: 0, Sender: 1, MessageID: 1, Vector: []int{0, 1, 0}, Type: ClientSend, Time: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{Process: 0, Peer: 1, Sender: 1, MessageID: 1, Vector: []int{2, 1, 0}, Type: ServerReceive, Time: time.Date(2024, 1, 1, 0, 0, 1, 0, time.UTC)},
+	}
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	for _, e := range events {
+		if err := w.Write(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	got, err := Read(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, events) {
+		t.Errorf("Read = %+v, want %+v", got, events)
+	}
+}
+
+func TestReadReportsTheBrokenEvent(t *testing.T) {
+	events, err := Read(strings.NewReader("{\"process\":1}\nnot json\n"))
+	if err == nil || !strings.Contains(err.Error(), "event 2") {
+		t.Errorf("Read error = %v, want one naming event 2", err)
+	}
+	if len(events) != 1 {
+		t.Errorf("Read returned %d events before the broken one, want 1", len(events))
+	}
+}
@@ -0,0 +1,172 @@
+package transport
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// Frame sent by a client when it connects, carrying its ID as Sender
+const hello = -1
+
+// How long Dial keeps retrying while the server is not up yet
+const dialTimeout = 10 * time.Second
+
+type tcpConn struct {
+	mu   sync.Mutex // Keeps frames from interleaving
+	conn net.Conn
+	id   int // Process at the other end, as given by its hello
+}
+
+func (c *tcpConn) send(msg Message) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return WriteMessage(c.conn, msg)
+}
+
+// Reads frames into in until the connection ends. Every frame goes through
+// check first, which may correct it or reject it with an error
+func (c *tcpConn) read(in *inbox, check func(*Message) error) {
+	for {
+		msg, err := ReadMessage(c.conn)
+		if err != nil {
+			return
+		}
+		if err := check(&msg); err != nil {
+			fmt.Fprintf(os.Stderr, "Dropping a frame from process %d: %v\n", c.id, err)
+			continue
+		}
+		in.push(msg)
+	}
+}
+
+type tcpServer struct {
+	listener net.Listener
+	clients  int
+	conns    map[int]*tcpConn
+	in       *inbox
+}
+
+// Listen accepts connections on addr until clients clients, numbered 1 to
+// clients, have connected, and returns the server's endpoint. Its receive
+// channel is closed once every client has disconnected. Every frame a client
+// sends arrives with the client's ID as its Sender, whatever the client wrote
+// there, and frames whose vectors do not have an entry for the server and
+// each client are dropped.
+func Listen(addr string, clients int) (Transport, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	s := &tcpServer{listener: listener, clients: clients, conns: make(map[int]*tcpConn), in: newInbox()}
+	for len(s.conns) < clients {
+		conn, err := listener.Accept()
+		if err != nil {
+			listener.Close()
+			return nil, err
+		}
+		msg, err := ReadMessage(conn)
+		if err != nil || msg.Kind != hello || msg.Sender < 1 || msg.Sender > clients || s.conns[msg.Sender] != nil {
+			conn.Close()
+			continue
+		}
+		s.conns[msg.Sender] = &tcpConn{conn: conn, id: msg.Sender}
+	}
+
+	var readers sync.WaitGroup
+	for _, c := range s.conns {
+		readers.Add(1)
+		go func(c *tcpConn) {
+			defer readers.Done()
+			c.read(s.in, func(msg *Message) error { return s.check(c, msg) })
+		}(c)
+	}
+	go func() {
+		readers.Wait()
+		s.in.close()
+	}()
+	return s, nil
+}
+
+// Ties a frame to the client whose connection it came on and checks the
+// lengths of its vectors
+func (s *tcpServer) check(c *tcpConn, msg *Message) error {
+	msg.Sender = c.id
+	if len(msg.Vector) != s.clients+1 {
+		return fmt.Errorf("vector has %d entries, want %d", len(msg.Vector), s.clients+1)
+	}
+	if msg.Causal != nil && len(msg.Causal) != s.clients+1 {
+		return fmt.Errorf("causal vector has %d entries, want %d", len(msg.Causal), s.clients+1)
+	}
+	return nil
+}
+
+func (s *tcpServer) Send(to int, msg Message) error {
+	c := s.conns[to]
+	if c == nil {
+		return fmt.Errorf("client %d is not connected", to)
+	}
+	return c.send(msg)
+}
+
+func (s *tcpServer) Receive() <-chan Message { return s.in.out }
+
+// Frames are written by Send, so there is nothing to flush
+func (s *tcpServer) Flush(to int) error { return nil }
+
+func (s *tcpServer) Close() error {
+	for _, c := range s.conns {
+		c.conn.Close()
+	}
+	return s.listener.Close()
+}
+
+type tcpClient struct {
+	c  *tcpConn
+	in *inbox
+}
+
+// Dial connects client id to the server at addr, retrying for a while if
+// the server is not listening yet, and returns the client's endpoint. Its
+// receive channel is closed when the server disconnects.
+func Dial(addr string, id int) (Transport, error) {
+	var conn net.Conn
+	var err error
+	for deadline := time.Now().Add(dialTimeout); ; {
+		conn, err = net.Dial("tcp", addr)
+		if err == nil || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+	if err != nil {
+		return nil, err
+	}
+	c := &tcpClient{c: &tcpConn{conn: conn}, in: newInbox()}
+	if err := c.c.send(Message{Kind: hello, Sender: id}); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	go func() {
+		// The server is the only process a client talks to, and checks what it relays
+		c.c.read(c.in, func(*Message) error { return nil })
+		c.in.close()
+	}()
+	return c, nil
+}
+
+func (c *tcpClient) Send(to int, msg Message) error {
+	if to != 0 {
+		return fmt.Errorf("clients can only send to the server, not to process %d", to)
+	}
+	return c.c.send(msg)
+}
+
+func (c *tcpClient) Receive() <-chan Message { return c.in.out }
+
+// Frames are written by Send, so there is nothing to flush
+func (c *tcpClient) Flush(to int) error { return nil }
+
+func (c *tcpClient) Close() error { return c.c.conn.Close() }
@@ -0,0 +1,69 @@
+package transport
+
+import (
+	"net"
+	"slices"
+	"testing"
+)
+
+// Returns a local address that nothing is listening on
+func freeAddress(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	return l.Addr().String()
+}
+
+func TestTCPServerDoesNotTrustTheWire(t *testing.T) {
+	addr := freeAddress(t)
+	type listened struct {
+		server Transport
+		err    error
+	}
+	done := make(chan listened)
+	go func() {
+		server, err := Listen(addr, 2)
+		done <- listened{server, err}
+	}()
+	var clients []Transport
+	for id := 1; id <= 2; id++ {
+		client, err := Dial(addr, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer client.Close()
+		clients = append(clients, client)
+	}
+	l := <-done
+	if l.err != nil {
+		t.Fatal(l.err)
+	}
+	defer l.server.Close()
+
+	// Client 1 claims to be client 2, then sends vectors of the wrong length
+	clients[0].Send(0, Message{Kind: 1, Sender: 2, MessageID: 1, Vector: []int{0, 1, 0}})
+	clients[0].Send(0, Message{Kind: 1, Sender: 1, MessageID: 2, Vector: []int{0, 2, 0, 9}})
+	clients[0].Send(0, Message{Kind: 1, Sender: 1, MessageID: 3, Vector: []int{0, 3, 0}, Causal: []int{1}})
+	clients[0].Send(0, Message{Kind: 1, Sender: 1, MessageID: 4, Vector: []int{0, 4, 0}})
+	clients[1].Send(0, Message{Kind: 1, Sender: 2, MessageID: 1, Vector: []int{0, 0, 1}, Causal: []int{0, 0, 1}})
+
+	var fromFirst []int
+	for received := 0; received < 3; received++ {
+		msg := <-l.server.Receive()
+		switch msg.Sender {
+		case 1:
+			fromFirst = append(fromFirst, msg.MessageID)
+		case 2:
+			if msg.MessageID != 1 || !slices.Equal(msg.Causal, []int{0, 0, 1}) {
+				t.Errorf("received %+v from client 2", msg)
+			}
+		default:
+			t.Errorf("received a message from client %d", msg.Sender)
+		}
+	}
+	if !slices.Equal(fromFirst, []int{1, 4}) {
+		t.Errorf("received messages %v from client 1, want [1 4]", fromFirst)
+	}
+}
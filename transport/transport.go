@@ -0,0 +1,201 @@
+// Package transport carries the messages of the Q1 programs between the
+// clients and the server, either within one process or over TCP so that
+// every node can run as its own process.
+package transport
+
+import (
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/Jashveragiwala/Lamport-and-Vector-Clocks/netsim"
+)
+
+// Transport is the endpoint of one process. Process 0 is the server and
+// clients are numbered from 1.
+type Transport interface {
+	// Send sends msg to process to without waiting for it to be received.
+	Send(to int, msg Message) error
+	// Receive returns the channel of messages sent to this process. Messages
+	// from one sender arrive in the order they were sent.
+	Receive() <-chan Message
+	// Flush blocks until every message sent to process to so far is on its
+	// way, so that a message sent afterwards arrives after them.
+	Flush(to int) error
+	// Close releases the endpoint.
+	Close() error
+}
+
+// Unbounded queue of received messages, so that senders never wait for the
+// receiver to catch up
+type inbox struct {
+	mu     sync.Mutex
+	queue  []Message
+	closed bool
+	wake   chan struct{}
+	out    chan Message
+}
+
+func newInbox() *inbox {
+	in := &inbox{wake: make(chan struct{}, 1), out: make(chan Message)}
+	go in.pump()
+	return in
+}
+
+func (in *inbox) push(msg Message) {
+	in.mu.Lock()
+	in.queue = append(in.queue, msg)
+	in.mu.Unlock()
+	in.signal()
+}
+
+// Closes the output channel once the queued messages have been received
+func (in *inbox) close() {
+	in.mu.Lock()
+	in.closed = true
+	in.mu.Unlock()
+	in.signal()
+}
+
+func (in *inbox) signal() {
+	select {
+	case in.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (in *inbox) pump() {
+	for {
+		in.mu.Lock()
+		if len(in.queue) == 0 {
+			closed := in.closed
+			in.mu.Unlock()
+			if closed {
+				close(in.out)
+				return
+			}
+			<-in.wake
+			continue
+		}
+		msg := in.queue[0]
+		in.queue = in.queue[1:]
+		in.mu.Unlock()
+		in.out <- msg
+	}
+}
+
+// Memory connects endpoints within one process, as the Go channels of the
+// original programs did.
+type Memory struct {
+	mu      sync.Mutex
+	inboxes map[int]*inbox
+}
+
+// NewMemory returns a hub without endpoints.
+func NewMemory() *Memory {
+	return &Memory{inboxes: make(map[int]*inbox)}
+}
+
+// Endpoint returns the endpoint of process id.
+func (m *Memory) Endpoint(id int) Transport {
+	return memoryEndpoint{m, m.inbox(id)}
+}
+
+func (m *Memory) inbox(id int) *inbox {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.inboxes[id] == nil {
+		m.inboxes[id] = newInbox()
+	}
+	return m.inboxes[id]
+}
+
+type memoryEndpoint struct {
+	hub *Memory
+	in  *inbox
+}
+
+func (e memoryEndpoint) Send(to int, msg Message) error {
+	// Copy the vectors as the wire encoding would
+	msg.Vector = append([]int(nil), msg.Vector...)
+	msg.Causal = append([]int(nil), msg.Causal...)
+	e.hub.inbox(to).push(msg)
+	return nil
+}
+
+func (e memoryEndpoint) Receive() <-chan Message { return e.in.out }
+func (e memoryEndpoint) Flush(to int) error      { return nil }
+func (e memoryEndpoint) Close() error {
+	e.in.close()
+	return nil
+}
+
+// Simulate returns an endpoint for process id that sends every message
+// through the simulated network before handing it to t.
+func Simulate(t Transport, id int, network *netsim.Network) Transport {
+	return simulated{t, id, network}
+}
+
+type simulated struct {
+	Transport
+	id      int
+	network *netsim.Network
+}
+
+func (s simulated) Send(to int, msg Message) error {
+	s.network.Send(s.id, to, func() {
+		if err := s.Transport.Send(to, msg); err != nil {
+			fmt.Fprintf(os.Stderr, "Could not send message to process %d: %v\n", to, err)
+		}
+	})
+	return nil
+}
+
+func (s simulated) Flush(to int) error {
+	s.network.Drain(s.id, to)
+	return s.Transport.Flush(to)
+}
+
+// Virtual connects the processes of a simulation. Messages travel through a
+// network timed by the simulation's engine, and every message is handed to
+// the handler of the receiving process by one of the engine's events.
+type Virtual struct {
+	network  *netsim.Network
+	handlers map[int]func(Message)
+}
+
+// NewVirtual returns a simulation without endpoints whose messages travel
+// through network.
+func NewVirtual(network *netsim.Network) *Virtual {
+	return &Virtual{network: network, handlers: make(map[int]func(Message))}
+}
+
+// Endpoint returns the endpoint of process id, whose messages are handed to
+// handle. Its Receive channel is never used.
+func (v *Virtual) Endpoint(id int, handle func(Message)) Transport {
+	v.handlers[id] = handle
+	return virtualEndpoint{v, id}
+}
+
+type virtualEndpoint struct {
+	sim *Virtual
+	id  int
+}
+
+func (e virtualEndpoint) Send(to int, msg Message) error {
+	handle := e.sim.handlers[to]
+	if handle == nil {
+		return fmt.Errorf("process %d is not part of the simulation", to)
+	}
+	msg.Vector = append([]int(nil), msg.Vector...)
+	msg.Causal = append([]int(nil), msg.Causal...)
+	e.sim.network.Send(e.id, to, func() { handle(msg) })
+	return nil
+}
+
+func (e virtualEndpoint) Receive() <-chan Message { return nil }
+
+// The simulation runs until every message has been delivered, so there is
+// nothing to wait for
+func (e virtualEndpoint) Flush(to int) error { return nil }
+func (e virtualEndpoint) Close() error       { return nil }
@@ -0,0 +1,114 @@
+package transport
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// MaxFrameSize is the largest frame ReadMessage accepts.
+const MaxFrameSize = 1 << 20
+
+// Message is the wire form of the messages exchanged by the Q1 programs.
+// Each program uses the fields it needs and leaves the others zero.
+type Message struct {
+	Kind      int
+	Sender    int // Client the message comes from, the acking client for an acknowledgement
+	MessageID int
+	Clock     int   // Lamport clock of the process sending the message
+	Timestamp int   // Lamport timestamp given by the original sender
+	Origin    int   // Original sender of the message an acknowledgement is for
+	Seq       int   // Number of the message on the link it was sent over
+	Vector    []int // Vector timestamp
+	Causal    []int // Broadcast counts used for causal delivery
+}
+
+// WriteMessage writes msg as a single frame: its length as a big-endian
+// uint32 followed by the fields as varints, each slice preceded by its length.
+func WriteMessage(w io.Writer, msg Message) error {
+	payload := make([]byte, 4, 64)
+	for _, v := range []int{msg.Kind, msg.Sender, msg.MessageID, msg.Clock, msg.Timestamp, msg.Origin, msg.Seq} {
+		payload = binary.AppendVarint(payload, int64(v))
+	}
+	for _, vector := range [][]int{msg.Vector, msg.Causal} {
+		payload = binary.AppendUvarint(payload, uint64(len(vector)))
+		for _, v := range vector {
+			payload = binary.AppendVarint(payload, int64(v))
+		}
+	}
+	binary.BigEndian.PutUint32(payload, uint32(len(payload)-4))
+	_, err := w.Write(payload)
+	return err
+}
+
+// ReadMessage reads a frame written by WriteMessage. It returns io.EOF if r
+// ends before a frame starts.
+func ReadMessage(r io.Reader) (Message, error) {
+	var header [4]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return Message{}, err
+	}
+	size := binary.BigEndian.Uint32(header[:])
+	if size > MaxFrameSize {
+		return Message{}, fmt.Errorf("frame of %d bytes is too large", size)
+	}
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return Message{}, err
+	}
+
+	d := decoder{payload: payload}
+	msg := Message{Kind: d.int(), Sender: d.int(), MessageID: d.int(), Clock: d.int(), Timestamp: d.int(), Origin: d.int(), Seq: d.int()}
+	msg.Vector = d.vector()
+	msg.Causal = d.vector()
+	if d.err == nil && len(d.payload) > 0 {
+		d.err = fmt.Errorf("%d trailing bytes", len(d.payload))
+	}
+	if d.err != nil {
+		return Message{}, fmt.Errorf("malformed frame: %w", d.err)
+	}
+	return msg, nil
+}
+
+// Reads varints from a frame, remembering the first error
+type decoder struct {
+	payload []byte
+	err     error
+}
+
+func (d *decoder) int() int {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Varint(d.payload)
+	if n <= 0 {
+		d.err = errors.New("invalid varint")
+		return 0
+	}
+	d.payload = d.payload[n:]
+	return int(v)
+}
+
+func (d *decoder) vector() []int {
+	if d.err != nil {
+		return nil
+	}
+	length, n := binary.Uvarint(d.payload)
+	if n <= 0 || length > uint64(len(d.payload)) {
+		d.err = errors.New("invalid vector length")
+		return nil
+	}
+	d.payload = d.payload[n:]
+	if length == 0 {
+		return nil
+	}
+	vector := make([]int, length)
+	for i := range vector {
+		vector[i] = d.int()
+	}
+	return vector
+}
@@ -0,0 +1,67 @@
+package transport
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+)
+
+func TestMessageRoundTrip(t *testing.T) {
+	messages := []Message{
+		{},
+		{Kind: 1, Sender: 3, MessageID: 42, Clock: 7, Timestamp: 5, Origin: 2, Seq: 9, Vector: []int{1, 0, 4, 2}},
+		{Kind: hello, Sender: 1},
+		{Kind: 1, Sender: 2, Vector: []int{0, 1 << 40, -3}, Causal: []int{0, 2, 0}},
+	}
+	var buf bytes.Buffer
+	for _, msg := range messages {
+		if err := WriteMessage(&buf, msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, want := range messages {
+		got, err := ReadMessage(&buf)
+		if err != nil {
+			t.Fatalf("ReadMessage: %v", err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("read %+v, want %+v", got, want)
+		}
+	}
+	if _, err := ReadMessage(&buf); err != io.EOF {
+		t.Errorf("ReadMessage at the end = %v, want io.EOF", err)
+	}
+}
+
+// Prefixes payload with its length as a frame header
+func frame(payload ...byte) []byte {
+	return append(binary.BigEndian.AppendUint32(nil, uint32(len(payload))), payload...)
+}
+
+func TestReadMessageRejectsMalformedFrames(t *testing.T) {
+	// Seven varint fields, then the lengths of the vector and the causal vector
+	fields := []byte{2, 2, 0, 0, 0, 0, 0}
+	tests := []struct {
+		name  string
+		frame []byte
+	}{
+		{"truncated", frame(append(fields, 0, 0)...)[:10]},
+		{"trailing bytes", frame(append(fields, 0, 0, 0)...)},
+		{"vector longer than the frame", frame(append(fields, 100)...)},
+		{"missing causal vector", frame(append(fields, 1, 2)...)},
+		{"too large", binary.BigEndian.AppendUint32(nil, MaxFrameSize+1)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ReadMessage(bytes.NewReader(tt.frame)); err == nil || errors.Is(err, io.EOF) {
+				t.Errorf("ReadMessage = %v, want an error other than io.EOF", err)
+			}
+		})
+	}
+	if msg, err := ReadMessage(bytes.NewReader(frame(append(fields, 0, 0)...))); err != nil || msg.Kind != 1 || msg.Sender != 1 {
+		t.Errorf("ReadMessage of a valid frame = %+v, %v", msg, err)
+	}
+}